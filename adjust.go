@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"time"
+)
+
+// adjust adjusts the image's colors, or shows a single channel of the image.
+// Colors are converted to grayscale or saturated, then have the brightness,
+// contrast, gamma, and invert adjustments applied, in that order.
+func (args *Args) adjust(img image.Image) (image.Image, error) {
+	channel, ok := channels[strings.ToLower(args.Channel)]
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("invalid channel %q", args.Channel)
+	case args.Gamma <= 0:
+		return nil, fmt.Errorf("invalid gamma %g", args.Gamma)
+	case !args.Grayscale && !args.Invert && args.Gamma == 1 && args.Brightness == 0 &&
+		args.Contrast == 0 && args.Saturation == 1 && channel == -1:
+		return img, nil
+	}
+	start := time.Now()
+	src := toNRGBA(img)
+	dst := image.NewNRGBA(src.Rect)
+	lut := args.adjustLUT()
+	saturation := args.Saturation
+	if args.Grayscale {
+		saturation = 0
+	}
+	for i := 0; i < len(src.Pix); i += 4 {
+		c := color.NRGBA{src.Pix[i], src.Pix[i+1], src.Pix[i+2], src.Pix[i+3]}
+		if saturation != 1 {
+			lum := float64(luminance(c))
+			c.R = clamp(lum + (float64(c.R)-lum)*saturation)
+			c.G = clamp(lum + (float64(c.G)-lum)*saturation)
+			c.B = clamp(lum + (float64(c.B)-lum)*saturation)
+		}
+		c.R, c.G, c.B = lut[c.R], lut[c.G], lut[c.B]
+		switch channel {
+		case 0, 1, 2:
+			v := [3]uint8{c.R, c.G, c.B}[channel]
+			c.R, c.G, c.B = v, v, v
+		case 3:
+			c = alphaChecker(i%src.Stride/4, i/src.Stride, c.A)
+		}
+		dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	args.logger("adjust: %v", time.Since(start))
+	return dst, nil
+}
+
+// adjustLUT returns the lookup table applying the brightness, contrast,
+// gamma, and invert adjustments to a color component.
+func (args *Args) adjustLUT() [256]uint8 {
+	var lut [256]uint8
+	for i := range lut {
+		v := float64(i) + 255*args.Brightness/100
+		v = (v-128)*(1+args.Contrast/100) + 128
+		v = 255 * math.Pow(max(v, 0)/255, 1/args.Gamma)
+		if args.Invert {
+			v = 255 - v
+		}
+		lut[i] = clamp(v)
+	}
+	return lut
+}
+
+// alphaChecker returns the alpha value of the pixel at x, y as white over a
+// checkerboard, so that transparent pixels show the checkerboard.
+func alphaChecker(x, y int, a uint8) color.NRGBA {
+	bg := 0x33
+	if (x/8+y/8)%2 == 1 {
+		bg = 0x66
+	}
+	v := uint8((int(a)*0xff + (0xff-int(a))*bg) / 0xff)
+	return color.NRGBA{v, v, v, 0xff}
+}
+
+// clamp rounds and clamps v to a color component.
+func clamp(v float64) uint8 {
+	return uint8(min(max(math.Round(v), 0), 255))
+}
+
+// channels are the channel view channels, by name. The alpha channel is 3.
+var channels = map[string]int{
+	"":      -1,
+	"r":     0,
+	"red":   0,
+	"g":     1,
+	"green": 1,
+	"b":     2,
+	"blue":  2,
+	"a":     3,
+	"alpha": 3,
+}
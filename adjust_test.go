@@ -0,0 +1,120 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestAdjustLUT(t *testing.T) {
+	tests := []struct {
+		args *Args
+		exp  map[int]uint8
+	}{
+		{&Args{Gamma: 1}, map[int]uint8{0: 0, 1: 1, 128: 128, 254: 254, 255: 255}},
+		{&Args{Gamma: 1, Invert: true}, map[int]uint8{0: 255, 100: 155, 255: 0}},
+		{&Args{Gamma: 1, Brightness: 10}, map[int]uint8{0: 26, 100: 126, 229: 255, 240: 255}},
+		{&Args{Gamma: 1, Brightness: -100}, map[int]uint8{0: 0, 128: 0, 255: 0}},
+		{&Args{Gamma: 1, Contrast: 100}, map[int]uint8{0: 0, 64: 0, 128: 128, 160: 192, 192: 255}},
+		{&Args{Gamma: 1, Contrast: -100}, map[int]uint8{0: 128, 128: 128, 255: 128}},
+		{&Args{Gamma: 2}, map[int]uint8{0: 0, 64: 128, 128: 181, 255: 255}},
+		{&Args{Gamma: 0.5}, map[int]uint8{0: 0, 64: 16, 128: 64, 255: 255}},
+		{&Args{Gamma: 2, Invert: true}, map[int]uint8{0: 255, 64: 127, 255: 0}},
+		{&Args{Gamma: 1, Brightness: 50, Contrast: 100}, map[int]uint8{0: 127, 32: 191, 64: 255}},
+	}
+	for i, test := range tests {
+		lut := test.args.adjustLUT()
+		for v, exp := range test.exp {
+			if lut[v] != exp {
+				t.Errorf("test %d expected lut[%d] = %d, got: %d", i, v, exp, lut[v])
+			}
+		}
+	}
+}
+
+func TestAdjust(t *testing.T) {
+	img := testImage(9, 1, func(x, y int) color.NRGBA {
+		switch x {
+		case 0:
+			return color.NRGBA{0xff, 0, 0, 0xff}
+		case 1:
+			return color.NRGBA{200, 100, 50, 0x80}
+		}
+		return color.NRGBA{10, 20, 30, 0}
+	})
+	tests := []struct {
+		args *Args
+		exp  []color.NRGBA
+		err  bool
+	}{
+		{
+			&Args{Gamma: 1, Saturation: 1},
+			[]color.NRGBA{{0xff, 0, 0, 0xff}, {200, 100, 50, 0x80}, {10, 20, 30, 0}, {10, 20, 30, 0}},
+			false,
+		},
+		{
+			&Args{Gamma: 1, Saturation: 1, Grayscale: true},
+			[]color.NRGBA{{76, 76, 76, 0xff}, {124, 124, 124, 0x80}, {18, 18, 18, 0}, {18, 18, 18, 0}},
+			false,
+		},
+		{
+			&Args{Gamma: 1, Saturation: 1, Grayscale: true, Invert: true},
+			[]color.NRGBA{{179, 179, 179, 0xff}, {131, 131, 131, 0x80}, {237, 237, 237, 0}, {237, 237, 237, 0}},
+			false,
+		},
+		{
+			&Args{Gamma: 1, Saturation: 2},
+			[]color.NRGBA{{0xff, 0, 0, 0xff}, {255, 76, 0, 0x80}, {2, 22, 42, 0}, {2, 22, 42, 0}},
+			false,
+		},
+		{
+			&Args{Gamma: 1, Saturation: 1, Channel: "r"},
+			[]color.NRGBA{{0xff, 0xff, 0xff, 0xff}, {200, 200, 200, 0x80}, {10, 10, 10, 0}, {10, 10, 10, 0}},
+			false,
+		},
+		{
+			&Args{Gamma: 1, Saturation: 1, Channel: "Green"},
+			[]color.NRGBA{{0, 0, 0, 0xff}, {100, 100, 100, 0x80}, {20, 20, 20, 0}, {20, 20, 20, 0}},
+			false,
+		},
+		{
+			&Args{Gamma: 1, Saturation: 1, Channel: "b", Invert: true},
+			[]color.NRGBA{{0xff, 0xff, 0xff, 0xff}, {205, 205, 205, 0x80}, {225, 225, 225, 0}, {225, 225, 225, 0}},
+			false,
+		},
+		{
+			// alpha over a checkerboard, switching at x = 8
+			&Args{Gamma: 1, Saturation: 1, Channel: "a"},
+			[]color.NRGBA{{0xff, 0xff, 0xff, 0xff}, {0x99, 0x99, 0x99, 0xff}, {0x33, 0x33, 0x33, 0xff}, {0x66, 0x66, 0x66, 0xff}},
+			false,
+		},
+		{&Args{Gamma: 1, Saturation: 1, Channel: "x"}, nil, true},
+		{&Args{Gamma: 0, Saturation: 1}, nil, true},
+		{&Args{Gamma: -1, Saturation: 1}, nil, true},
+	}
+	for i, test := range tests {
+		test.args.logger = t.Logf
+		got, err := test.args.adjust(img)
+		switch {
+		case test.err && err == nil:
+			t.Errorf("test %d expected error", i)
+			continue
+		case test.err:
+			continue
+		case err != nil:
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if got.Bounds() != img.Bounds() {
+			t.Fatalf("test %d expected %v, got: %v", i, img.Bounds(), got.Bounds())
+		}
+		for j, x := range []int{0, 1, 2, 8} {
+			if c := testNRGBA(got, x, 0); c != test.exp[j] {
+				t.Errorf("test %d pixel %d expected %v, got: %v", i, x, test.exp[j], c)
+			}
+		}
+	}
+	// no adjustments returns the image
+	if got, err := (&Args{Gamma: 1, Saturation: 1}).adjust(img); err != nil || got != image.Image(img) {
+		t.Errorf("expected the same image, got: %v", err)
+	}
+}
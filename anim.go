@@ -0,0 +1,561 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"golang.org/x/image/webp"
+	"golang.org/x/term"
+)
+
+// animation is a decoded animation. Satisfies the [image.Image] interface
+// using the first frame.
+type animation struct {
+	image.Image
+	frames []image.Image
+	delays []time.Duration
+	// loops is the number of times to play the animation, 0 being forever
+	loops int
+}
+
+// newAnimation creates a new animation, or returns the frame when the
+// animation has only a single frame, or the selected page when page is not 0.
+func newAnimation(frames []image.Image, delays []time.Duration, loops int, page uint) image.Image {
+	switch {
+	case page != 0:
+		return frames[page-1]
+	case len(frames) == 1:
+		return frames[0]
+	}
+	return &animation{
+		Image:  frames[0],
+		frames: frames,
+		delays: delays,
+		loops:  loops,
+	}
+}
+
+// decodeAnimation decodes an animated gif, png, or webp, returning the first
+// frame for images that are not animated.
+func (args *Args) decodeAnimation(mime string, buf []byte) (image.Image, error) {
+	var frames []image.Image
+	var delays []time.Duration
+	var loops int
+	var err error
+	start := time.Now()
+	switch mime {
+	case "image/gif":
+		frames, delays, loops, err = decodeGIF(buf)
+	case "image/png", "image/apng":
+		frames, delays, loops, err = decodeAPNG(buf)
+	case "image/webp":
+		frames, delays, loops, err = decodeAnimatedWebP(buf)
+	default:
+		return nil, fmt.Errorf("animation: mime type %q: not supported", mime)
+	}
+	if err != nil {
+		return nil, err
+	}
+	args.logger("animation frames: %d loops: %d", len(frames), loops)
+	args.logger("animation decode: %v", time.Since(start))
+	args.pages = len(frames)
+	if _, err := args.pageIndex(len(frames)); err != nil {
+		return nil, err
+	}
+	return newAnimation(frames, delays, loops, args.Page), nil
+}
+
+// play plays the animation in place to w, until the animation's loop count
+// is reached or the context is closed.
+func (args *Args) play(w io.Writer, a *animation, mime string) error {
+	frames := make([]image.Image, len(a.frames))
+	for i, frame := range a.frames {
+		frame, err := args.transform(frame)
+		if err != nil {
+			return err
+		}
+		frames[i] = args.scale(args.addBackground(mime, frame))
+	}
+	loops := a.loops
+	switch {
+	case args.Once:
+		loops = 1
+	case args.Loop:
+		loops = 0
+	}
+	p := args.newPlacer(w, frames[0], true)
+	defer p.close()
+	for n := 0; loops == 0 || n < loops; n++ {
+		for i, img := range frames {
+			start := time.Now()
+			if err := p.draw(img); err != nil {
+				return err
+			}
+			select {
+			case <-args.ctx.Done():
+				return nil
+			case <-time.After(a.delays[i] - time.Since(start)):
+			}
+		}
+	}
+	return nil
+}
+
+// placer draws images in place. Kitty images are transmitted once and
+// placed by id, replacing the previous placement.
+type placer struct {
+	w      io.Writer
+	encode func(io.Writer, image.Image) error
+	images *kittyImages
+	// keep retains replaced kitty images until closed, for images that are
+	// drawn again (ex: animation frames)
+	keep      bool
+	kept      []*kittyImage
+	prev      *kittyImage
+	placement uint32
+}
+
+// newPlacer creates a placer, reserving rows for images the same size as img
+// so that the terminal does not scroll between draws.
+func (args *Args) newPlacer(w io.Writer, img image.Image, keep bool) *placer {
+	if rows := args.imageRows(img); rows != 0 {
+		fmt.Fprintf(w, "%s\x1b[%dA", bytes.Repeat([]byte{'\n'}, rows), rows)
+	}
+	// hide cursor, save position
+	fmt.Fprint(w, "\x1b[?25l\x1b7")
+	return &placer{
+		w:      w,
+		encode: args.encode,
+		images: args.images,
+		keep:   keep,
+	}
+}
+
+// draw draws the image at the saved position, removing the previously drawn
+// image.
+func (p *placer) draw(img image.Image) error {
+	fmt.Fprint(p.w, "\x1b8")
+	if p.images == nil {
+		return p.encode(p.w, img)
+	}
+	k, err := p.images.transmit(p.w, img)
+	if err != nil {
+		return err
+	}
+	placement, err := p.images.place(p.w, k, 0, 0, 0, 0)
+	if err != nil {
+		return err
+	}
+	if p.prev != nil {
+		if err := p.images.remove(p.w, p.prev, p.placement, !p.keep && p.prev != k); err != nil {
+			return err
+		}
+	}
+	if p.keep && !slices.Contains(p.kept, k) {
+		p.kept = append(p.kept, k)
+	}
+	p.prev, p.placement = k, placement
+	return nil
+}
+
+// close restores the cursor, and deletes the kept kitty images other than
+// the last drawn image.
+func (p *placer) close() {
+	for _, k := range p.kept {
+		if k != p.prev {
+			p.images.remove(p.w, k, 0, true)
+		}
+	}
+	fmt.Fprint(p.w, "\x1b[?25h")
+}
+
+// isAnimated returns true when the image is an animation that can be played
+// to the terminal.
+func isAnimated(img image.Image) (*animation, bool) {
+	a, ok := img.(*animation)
+	return a, ok && term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// imageRows returns the number of terminal rows the image occupies, or 0 when
+// the terminal's cell size is not known.
+func (args *Args) imageRows(img image.Image) int {
+	_, ch, ok := args.cellSize()
+	if !ok {
+		return 0
+	}
+	return (img.Bounds().Dy() + ch - 1) / ch
+}
+
+// decodeGIF decodes a gif animation, composing the frames.
+func decodeGIF(buf []byte) ([]image.Image, []time.Duration, int, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(buf))
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	r := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	canvas := image.NewNRGBA(r)
+	var frames []image.Image
+	var delays []time.Duration
+	for i, frame := range g.Image {
+		var prev *image.NRGBA
+		if i < len(g.Disposal) && g.Disposal[i] == gif.DisposalPrevious {
+			prev = cloneNRGBA(canvas)
+		}
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		frames = append(frames, cloneNRGBA(canvas))
+		delays = append(delays, gifDelay(g.Delay[i]))
+		switch {
+		case i >= len(g.Disposal):
+		case g.Disposal[i] == gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case g.Disposal[i] == gif.DisposalPrevious:
+			canvas = prev
+		}
+	}
+	loops := 0
+	switch {
+	case g.LoopCount < 0:
+		loops = 1
+	case g.LoopCount > 0:
+		loops = g.LoopCount + 1
+	}
+	return frames, delays, loops, nil
+}
+
+// gifDelay returns the gif delay (in 100ths of a second) as a duration, using
+// the same minimum delay as web browsers.
+func gifDelay(delay int) time.Duration {
+	if delay <= 1 {
+		delay = 10
+	}
+	return time.Duration(delay) * 10 * time.Millisecond
+}
+
+// decodeAPNG decodes an animated png, composing the frames. Decodes as a
+// regular png when there is no animation control chunk.
+//
+// See: https://wiki.mozilla.org/APNG_Specification
+func decodeAPNG(buf []byte) ([]image.Image, []time.Duration, int, error) {
+	if !bytes.HasPrefix(buf, pngSig) {
+		return nil, nil, 0, errors.New("apng: invalid signature")
+	}
+	var ihdr []byte
+	var shared [][]byte
+	var frames []apngFrame
+	var loops int
+	animated := false
+	for b := buf[len(pngSig):]; len(b) >= 12; {
+		n := binary.BigEndian.Uint32(b)
+		if uint64(len(b)) < 12+uint64(n) {
+			return nil, nil, 0, errors.New("apng: truncated chunk")
+		}
+		typ, data := string(b[4:8]), b[8:8+n]
+		chunk := b[:12+n]
+		b = b[12+n:]
+		switch typ {
+		case "IHDR":
+			ihdr = data
+		case "acTL":
+			if len(data) != 8 {
+				return nil, nil, 0, errors.New("apng: invalid acTL")
+			}
+			animated, loops = true, int(binary.BigEndian.Uint32(data[4:]))
+		case "fcTL":
+			if len(data) != 26 {
+				return nil, nil, 0, errors.New("apng: invalid fcTL")
+			}
+			frames = append(frames, apngFrame{
+				w:       binary.BigEndian.Uint32(data[4:]),
+				h:       binary.BigEndian.Uint32(data[8:]),
+				x:       int(binary.BigEndian.Uint32(data[12:])),
+				y:       int(binary.BigEndian.Uint32(data[16:])),
+				delay:   apngDelay(binary.BigEndian.Uint16(data[20:]), binary.BigEndian.Uint16(data[22:])),
+				dispose: data[24],
+				blend:   data[25],
+			})
+		case "IDAT":
+			// idat is only part of the animation when preceded by fctl
+			if len(frames) != 0 {
+				frames[len(frames)-1].data = append(frames[len(frames)-1].data, data)
+			}
+		case "fdAT":
+			if len(frames) != 0 && len(data) >= 4 {
+				frames[len(frames)-1].data = append(frames[len(frames)-1].data, data[4:])
+			}
+		case "IEND":
+		default:
+			if len(frames) == 0 {
+				shared = append(shared, chunk)
+			}
+		}
+	}
+	if !animated || len(frames) == 0 || len(ihdr) != 13 {
+		img, err := png.Decode(bytes.NewReader(buf))
+		return []image.Image{img}, []time.Duration{0}, 1, err
+	}
+	// decode and compose
+	canvas := image.NewNRGBA(image.Rect(
+		0, 0,
+		int(binary.BigEndian.Uint32(ihdr)),
+		int(binary.BigEndian.Uint32(ihdr[4:])),
+	))
+	var imgs []image.Image
+	var delays []time.Duration
+	for i, frame := range frames {
+		img, err := frame.decode(ihdr, shared)
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("apng: frame %d: %w", i, err)
+		}
+		r := img.Bounds().Add(image.Pt(frame.x, frame.y))
+		var prev *image.NRGBA
+		if frame.dispose == 2 && i != 0 {
+			prev = cloneNRGBA(canvas)
+		}
+		op := draw.Over
+		if frame.blend == 0 {
+			op = draw.Src
+		}
+		draw.Draw(canvas, r, img, img.Bounds().Min, op)
+		imgs = append(imgs, cloneNRGBA(canvas))
+		delays = append(delays, frame.delay)
+		switch {
+		case frame.dispose == 1, frame.dispose == 2 && i == 0:
+			draw.Draw(canvas, r, image.Transparent, image.Point{}, draw.Src)
+		case frame.dispose == 2:
+			canvas = prev
+		}
+	}
+	return imgs, delays, loops, nil
+}
+
+// apngFrame is an animated png frame.
+type apngFrame struct {
+	w, h    uint32
+	x, y    int
+	delay   time.Duration
+	dispose byte
+	blend   byte
+	data    [][]byte
+}
+
+// decode decodes the frame by building a standalone png from the frame's
+// data.
+func (frame apngFrame) decode(ihdr []byte, shared [][]byte) (image.Image, error) {
+	buf := bytes.NewBuffer(append([]byte(nil), pngSig...))
+	hdr := append([]byte(nil), ihdr...)
+	binary.BigEndian.PutUint32(hdr, frame.w)
+	binary.BigEndian.PutUint32(hdr[4:], frame.h)
+	writePNGChunk(buf, "IHDR", hdr)
+	for _, chunk := range shared {
+		buf.Write(chunk)
+	}
+	for _, data := range frame.data {
+		writePNGChunk(buf, "IDAT", data)
+	}
+	writePNGChunk(buf, "IEND", nil)
+	return png.Decode(buf)
+}
+
+// apngDelay returns the apng frame delay as a duration.
+func apngDelay(num, den uint16) time.Duration {
+	if den == 0 {
+		den = 100
+	}
+	if num == 0 {
+		return 10 * time.Millisecond
+	}
+	return time.Duration(num) * time.Second / time.Duration(den)
+}
+
+// writePNGChunk writes a png chunk to buf.
+func writePNGChunk(buf *bytes.Buffer, typ string, data []byte) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(len(data)))
+	buf.Write(b[:])
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(typ))
+	crc.Write(data)
+	buf.WriteString(typ)
+	buf.Write(data)
+	binary.BigEndian.PutUint32(b[:], crc.Sum32())
+	buf.Write(b[:])
+}
+
+// pngSig is the png signature.
+var pngSig = []byte("\x89PNG\r\n\x1a\n")
+
+// decodeAnimatedWebP decodes an animated webp, composing the frames. Decodes
+// as a regular webp when the animation flag is not set.
+//
+// See: https://developers.google.com/speed/webp/docs/riff_container
+func decodeAnimatedWebP(buf []byte) ([]image.Image, []time.Duration, int, error) {
+	if len(buf) < 12 || string(buf[:4]) != "RIFF" || string(buf[8:12]) != "WEBP" {
+		return nil, nil, 0, errors.New("webp: invalid header")
+	}
+	var canvas *image.NRGBA
+	var frames []image.Image
+	var delays []time.Duration
+	var loops int
+	animated := false
+	err := riffChunks(buf[12:], func(typ string, data []byte) error {
+		switch typ {
+		case "VP8X":
+			if len(data) < 10 {
+				return errors.New("webp: invalid VP8X")
+			}
+			animated = data[0]&0x02 != 0
+			canvas = image.NewNRGBA(image.Rect(0, 0, int(uint24(data[4:]))+1, int(uint24(data[7:]))+1))
+		case "ANIM":
+			if len(data) < 6 {
+				return errors.New("webp: invalid ANIM")
+			}
+			loops = int(binary.LittleEndian.Uint16(data[4:]))
+		case "ANMF":
+			if !animated || canvas == nil || len(data) < 16 {
+				return errors.New("webp: invalid ANMF")
+			}
+			img, err := decodeWebPFrame(data[16:])
+			if err != nil {
+				return fmt.Errorf("webp: frame %d: %w", len(frames), err)
+			}
+			x, y := 2*int(uint24(data)), 2*int(uint24(data[3:]))
+			r := img.Bounds().Sub(img.Bounds().Min).Add(image.Pt(x, y))
+			op := draw.Over
+			if data[15]&0x02 != 0 {
+				op = draw.Src
+			}
+			draw.Draw(canvas, r, img, img.Bounds().Min, op)
+			frames = append(frames, cloneNRGBA(canvas))
+			delays = append(delays, max(time.Duration(uint24(data[12:]))*time.Millisecond, 10*time.Millisecond))
+			if data[15]&0x01 != 0 {
+				draw.Draw(canvas, r, image.Transparent, image.Point{}, draw.Src)
+			}
+		}
+		return nil
+	})
+	switch {
+	case err != nil:
+		return nil, nil, 0, err
+	case !animated:
+		img, err := webp.Decode(bytes.NewReader(buf))
+		return []image.Image{img}, []time.Duration{0}, 1, err
+	case len(frames) == 0:
+		return nil, nil, 0, errors.New("webp: no frames")
+	}
+	return frames, delays, loops, nil
+}
+
+// decodeWebPFrame decodes the frame data of an animated webp frame by building
+// a standalone webp from the frame's data.
+func decodeWebPFrame(buf []byte) (image.Image, error) {
+	var alph, vp8 []byte
+	var lossless bool
+	var w, h int
+	err := riffChunks(buf, func(typ string, data []byte) error {
+		switch typ {
+		case "ALPH":
+			alph = data
+		case "VP8 ":
+			vp8 = data
+			if len(data) >= 10 {
+				w, h = int(binary.LittleEndian.Uint16(data[6:])&0x3fff), int(binary.LittleEndian.Uint16(data[8:])&0x3fff)
+			}
+		case "VP8L":
+			vp8, lossless = data, true
+		}
+		return nil
+	})
+	switch {
+	case err != nil:
+		return nil, err
+	case vp8 == nil:
+		return nil, errors.New("missing image data")
+	}
+	var b bytes.Buffer
+	switch {
+	case lossless:
+		writeRIFFChunk(&b, "VP8L", vp8)
+	case alph != nil:
+		vp8x := make([]byte, 10)
+		vp8x[0] = 0x10
+		putUint24(vp8x[4:], uint32(w-1))
+		putUint24(vp8x[7:], uint32(h-1))
+		writeRIFFChunk(&b, "VP8X", vp8x)
+		writeRIFFChunk(&b, "ALPH", alph)
+		writeRIFFChunk(&b, "VP8 ", vp8)
+	default:
+		writeRIFFChunk(&b, "VP8 ", vp8)
+	}
+	hdr := make([]byte, 12)
+	copy(hdr, "RIFF")
+	binary.LittleEndian.PutUint32(hdr[4:], uint32(4+b.Len()))
+	copy(hdr[8:], "WEBP")
+	return webp.Decode(io.MultiReader(bytes.NewReader(hdr), &b))
+}
+
+// riffChunks calls f for each riff chunk in buf.
+func riffChunks(buf []byte, f func(string, []byte) error) error {
+	for len(buf) >= 8 {
+		n := uint64(binary.LittleEndian.Uint32(buf[4:]))
+		if uint64(len(buf)) < 8+n {
+			return errors.New("riff: truncated chunk")
+		}
+		if err := f(string(buf[:4]), buf[8:8+n]); err != nil {
+			return err
+		}
+		buf = buf[min(8+n+n&1, uint64(len(buf))):]
+	}
+	return nil
+}
+
+// writeRIFFChunk writes a riff chunk to buf.
+func writeRIFFChunk(buf *bytes.Buffer, typ string, data []byte) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(len(data)))
+	buf.WriteString(typ)
+	buf.Write(b[:])
+	buf.Write(data)
+	if len(data)&1 != 0 {
+		buf.WriteByte(0)
+	}
+}
+
+// uint24 decodes a little endian 24-bit unsigned integer.
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+// putUint24 encodes a little endian 24-bit unsigned integer.
+func putUint24(b []byte, v uint32) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
+
+// cloneNRGBA returns a copy of the image.
+func cloneNRGBA(img *image.NRGBA) *image.NRGBA {
+	dst := image.NewNRGBA(img.Rect)
+	copy(dst.Pix, img.Pix)
+	return dst
+}
+
+// toNRGBA returns the image as an NRGBA image with a zero origin, converting
+// the image when necessary.
+func toNRGBA(img image.Image) *image.NRGBA {
+	b := img.Bounds()
+	if dst, ok := img.(*image.NRGBA); ok && b.Min == (image.Point{}) {
+		return dst
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"golang.org/x/image/webp"
+)
+
+func TestDecodeAPNG(t *testing.T) {
+	red := testImage(4, 4, func(x, y int) color.NRGBA {
+		if x == 3 && y == 3 {
+			return color.NRGBA{}
+		}
+		return color.NRGBA{0xff, 0, 0, 0xff}
+	})
+	blue := testImage(2, 2, func(int, int) color.NRGBA { return color.NRGBA{0, 0, 0xff, 0x80} })
+	green := testImage(2, 2, func(x, y int) color.NRGBA {
+		if x == 1 && y == 1 {
+			return color.NRGBA{}
+		}
+		return color.NRGBA{0, 0xff, 0, 0xff}
+	})
+	buf := testAPNG(t, 2, []testAPNGFrame{
+		{red, 0, 0, 1, 10, 0, 0},
+		{blue, 1, 1, 0, 0, 1, 1},
+		{green, 2, 2, 50, 1000, 0, 0},
+	})
+	imgs, delays, loops, err := decodeAPNG(buf)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := []time.Duration{100 * time.Millisecond, 10 * time.Millisecond, 50 * time.Millisecond}; !slices.Equal(delays, exp) {
+		t.Errorf("expected delays %v, got: %v", exp, delays)
+	}
+	if loops != 2 {
+		t.Errorf("expected loops 2, got: %d", loops)
+	}
+	if len(imgs) != 3 {
+		t.Fatalf("expected 3 frames, got: %d", len(imgs))
+	}
+	tests := []struct {
+		frame int
+		x, y  int
+		exp   color.NRGBA
+	}{
+		{0, 0, 0, color.NRGBA{0xff, 0, 0, 0xff}},
+		{0, 1, 1, color.NRGBA{0xff, 0, 0, 0xff}},
+		{0, 3, 3, color.NRGBA{}},
+		// blended over
+		{1, 0, 0, color.NRGBA{0xff, 0, 0, 0xff}},
+		{1, 1, 1, color.NRGBA{0x7f, 0, 0x80, 0xff}},
+		{1, 3, 3, color.NRGBA{}},
+		// disposed to the background, then replaced
+		{2, 0, 0, color.NRGBA{0xff, 0, 0, 0xff}},
+		{2, 1, 1, color.NRGBA{}},
+		{2, 2, 2, color.NRGBA{0, 0xff, 0, 0xff}},
+		{2, 3, 3, color.NRGBA{}},
+	}
+	for i, test := range tests {
+		img := imgs[test.frame]
+		if b := img.Bounds(); b != image.Rect(0, 0, 4, 4) {
+			t.Errorf("test %d expected 4x4 frame, got: %v", i, b)
+			continue
+		}
+		if c := color.NRGBAModel.Convert(img.At(test.x, test.y)).(color.NRGBA); c != test.exp {
+			t.Errorf("test %d frame %d expected %v at %d,%d, got: %v", i, test.frame, test.exp, test.x, test.y, c)
+		}
+	}
+}
+
+func TestDecodeAPNGStill(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, testImage(3, 2, func(int, int) color.NRGBA { return color.NRGBA{1, 2, 3, 4} })); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	imgs, delays, loops, err := decodeAPNG(buf.Bytes())
+	switch {
+	case err != nil:
+		t.Fatalf("expected no error, got: %v", err)
+	case len(imgs) != 1 || imgs[0].Bounds() != image.Rect(0, 0, 3, 2):
+		t.Errorf("expected a single 3x2 frame, got: %d", len(imgs))
+	case !slices.Equal(delays, []time.Duration{0}) || loops != 1:
+		t.Errorf("expected delays [0] and loops 1, got: %v %d", delays, loops)
+	}
+}
+
+func TestDecodeAPNGErrors(t *testing.T) {
+	valid := testAPNG(t, 0, []testAPNGFrame{
+		{testImage(2, 2, func(int, int) color.NRGBA { return color.NRGBA{0, 0, 0, 0x80} }), 0, 0, 1, 10, 0, 0},
+	})
+	invalidACTL := bytes.NewBuffer(slices.Clone(pngSig))
+	writePNGChunk(invalidACTL, "acTL", []byte{0, 0, 0, 1})
+	invalidFCTL := bytes.NewBuffer(slices.Clone(pngSig))
+	writePNGChunk(invalidFCTL, "fcTL", make([]byte, 25))
+	tests := [][]byte{
+		nil,
+		[]byte("GIF89a"),
+		valid[:len(pngSig)+10],
+		invalidACTL.Bytes(),
+		invalidFCTL.Bytes(),
+	}
+	for i, buf := range tests {
+		if _, _, _, err := decodeAPNG(buf); err == nil {
+			t.Errorf("test %d expected error", i)
+		}
+	}
+}
+
+func TestDecodeAnimatedWebP(t *testing.T) {
+	lossless, lossy := testWebPChunks(t, "testdata/logo/4_webp_ll.webp"), testWebPChunks(t, "testdata/logo/4_webp_a.webp")
+	buf := testAnimatedWebP(t, 450, 420, 3, []testWebPFrame{
+		{lossless, 0, 0, 100, 0x02},
+		{lossy, 10, 20, 0, 0x01},
+		{lossless, 20, 0, 50, 0x00},
+	})
+	imgs, delays, loops, err := decodeAnimatedWebP(buf)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := []time.Duration{100 * time.Millisecond, 10 * time.Millisecond, 50 * time.Millisecond}; !slices.Equal(delays, exp) {
+		t.Errorf("expected delays %v, got: %v", exp, delays)
+	}
+	if loops != 3 {
+		t.Errorf("expected loops 3, got: %d", loops)
+	}
+	if len(imgs) != 3 {
+		t.Fatalf("expected 3 frames, got: %d", len(imgs))
+	}
+	logo := testWebPImage(t, "testdata/logo/4_webp_ll.webp")
+	b := logo.Bounds()
+	for i, img := range imgs {
+		if r := img.Bounds(); r != image.Rect(0, 0, 450, 420) {
+			t.Errorf("frame %d expected 450x420, got: %v", i, r)
+		}
+	}
+	tests := []struct {
+		frame int
+		x, y  int
+		exp   color.NRGBA
+	}{
+		{0, 60, 40, testNRGBA(logo, 60, 40)},
+		{0, b.Dx() + 1, 0, color.NRGBA{}},
+		{0, 449, 419, color.NRGBA{}},
+		// disposed to the background
+		{2, b.Dx() + 5, b.Dy() + 10, color.NRGBA{}},
+		{2, 0, 0, testNRGBA(logo, 0, 0)},
+		{2, 20 + 60, 40, testNRGBA(logo, 60, 40)},
+	}
+	for i, test := range tests {
+		if c := color.NRGBAModel.Convert(imgs[test.frame].At(test.x, test.y)).(color.NRGBA); c != test.exp {
+			t.Errorf("test %d frame %d expected %v at %d,%d, got: %v", i, test.frame, test.exp, test.x, test.y, c)
+		}
+	}
+}
+
+func TestDecodeAnimatedWebPStill(t *testing.T) {
+	buf, err := os.ReadFile("testdata/logo/4_webp_a.webp")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	imgs, delays, loops, err := decodeAnimatedWebP(buf)
+	switch {
+	case err != nil:
+		t.Fatalf("expected no error, got: %v", err)
+	case len(imgs) != 1:
+		t.Errorf("expected a single frame, got: %d", len(imgs))
+	case !slices.Equal(delays, []time.Duration{0}) || loops != 1:
+		t.Errorf("expected delays [0] and loops 1, got: %v %d", delays, loops)
+	}
+}
+
+func TestDecodeAnimatedWebPErrors(t *testing.T) {
+	lossless := testWebPChunks(t, "testdata/logo/4_webp_ll.webp")
+	valid := testAnimatedWebP(t, 450, 420, 0, []testWebPFrame{{lossless, 0, 0, 100, 0}})
+	// frame without the animation flag
+	still := new(bytes.Buffer)
+	writeRIFFChunk(still, "VP8X", make([]byte, 10))
+	anmf := make([]byte, 16)
+	writeRIFFChunk(still, "ANMF", append(anmf, lossless...))
+	// animation without frames
+	empty := new(bytes.Buffer)
+	writeRIFFChunk(empty, "VP8X", []byte{0x02, 0, 0, 0, 1, 0, 0, 1, 0, 0})
+	tests := [][]byte{
+		nil,
+		[]byte("RIFF\x00\x00\x00\x00WEBX"),
+		valid[:len(valid)-100],
+		testRIFF(still.Bytes()),
+		testRIFF(empty.Bytes()),
+	}
+	for i, buf := range tests {
+		if _, _, _, err := decodeAnimatedWebP(buf); err == nil {
+			t.Errorf("test %d expected error", i)
+		}
+	}
+}
+
+func TestRIFFChunks(t *testing.T) {
+	buf := new(bytes.Buffer)
+	writeRIFFChunk(buf, "ABCD", []byte("odd"))
+	writeRIFFChunk(buf, "EFGH", []byte("even"))
+	writeRIFFChunk(buf, "IJKL", nil)
+	var typs []string
+	var data []string
+	if err := riffChunks(buf.Bytes(), func(typ string, b []byte) error {
+		typs, data = append(typs, typ), append(data, string(b))
+		return nil
+	}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := []string{"ABCD", "EFGH", "IJKL"}; !slices.Equal(typs, exp) {
+		t.Errorf("expected %q, got: %q", exp, typs)
+	}
+	if exp := []string{"odd", "even", ""}; !slices.Equal(data, exp) {
+		t.Errorf("expected %q, got: %q", exp, data)
+	}
+	if err := riffChunks(buf.Bytes()[:10], func(string, []byte) error { return nil }); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+// testAPNGFrame is an apng test frame.
+type testAPNGFrame struct {
+	img            *image.NRGBA
+	x, y           uint32
+	num, den       uint16
+	dispose, blend byte
+}
+
+// testAPNG builds an apng from the frames, the first of which is the default
+// image.
+func testAPNG(t *testing.T, loops uint32, frames []testAPNGFrame) []byte {
+	t.Helper()
+	buf := bytes.NewBuffer(slices.Clone(pngSig))
+	var seq uint32
+	for i, frame := range frames {
+		ihdr, data := testPNGChunks(t, frame.img)
+		if i == 0 {
+			writePNGChunk(buf, "IHDR", ihdr)
+			actl := make([]byte, 8)
+			binary.BigEndian.PutUint32(actl, uint32(len(frames)))
+			binary.BigEndian.PutUint32(actl[4:], loops)
+			writePNGChunk(buf, "acTL", actl)
+		}
+		b := frame.img.Bounds()
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl, seq)
+		binary.BigEndian.PutUint32(fctl[4:], uint32(b.Dx()))
+		binary.BigEndian.PutUint32(fctl[8:], uint32(b.Dy()))
+		binary.BigEndian.PutUint32(fctl[12:], frame.x)
+		binary.BigEndian.PutUint32(fctl[16:], frame.y)
+		binary.BigEndian.PutUint16(fctl[20:], frame.num)
+		binary.BigEndian.PutUint16(fctl[22:], frame.den)
+		fctl[24], fctl[25] = frame.dispose, frame.blend
+		writePNGChunk(buf, "fcTL", fctl)
+		seq++
+		if i == 0 {
+			writePNGChunk(buf, "IDAT", data)
+			continue
+		}
+		fdat := binary.BigEndian.AppendUint32(nil, seq)
+		writePNGChunk(buf, "fdAT", append(fdat, data...))
+		seq++
+	}
+	writePNGChunk(buf, "IEND", nil)
+	return buf.Bytes()
+}
+
+// testPNGChunks returns the IHDR chunk and the combined IDAT chunk data of
+// the png encoded image.
+func testPNGChunks(t *testing.T, img image.Image) ([]byte, []byte) {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	var ihdr, data []byte
+	for b := buf.Bytes()[len(pngSig):]; len(b) >= 12; {
+		n := binary.BigEndian.Uint32(b)
+		switch string(b[4:8]) {
+		case "IHDR":
+			ihdr = b[8 : 8+n]
+		case "IDAT":
+			data = append(data, b[8:8+n]...)
+		}
+		b = b[12+n:]
+	}
+	return ihdr, data
+}
+
+// testWebPFrame is an animated webp test frame.
+type testWebPFrame struct {
+	chunks   []byte
+	x, y     int
+	duration uint32
+	flags    byte
+}
+
+// testAnimatedWebP builds an animated webp of the canvas size from the
+// frames.
+func testAnimatedWebP(t *testing.T, w, h int, loops uint16, frames []testWebPFrame) []byte {
+	t.Helper()
+	chunks := new(bytes.Buffer)
+	vp8x := make([]byte, 10)
+	vp8x[0] = 0x02 | 0x10
+	putUint24(vp8x[4:], uint32(w-1))
+	putUint24(vp8x[7:], uint32(h-1))
+	writeRIFFChunk(chunks, "VP8X", vp8x)
+	writeRIFFChunk(chunks, "ANIM", binary.LittleEndian.AppendUint16(make([]byte, 4), loops))
+	for _, frame := range frames {
+		img, err := decodeWebPFrame(frame.chunks)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		b := img.Bounds()
+		anmf := make([]byte, 16)
+		putUint24(anmf, uint32(frame.x/2))
+		putUint24(anmf[3:], uint32(frame.y/2))
+		putUint24(anmf[6:], uint32(b.Dx()-1))
+		putUint24(anmf[9:], uint32(b.Dy()-1))
+		putUint24(anmf[12:], frame.duration)
+		anmf[15] = frame.flags
+		writeRIFFChunk(chunks, "ANMF", append(anmf, frame.chunks...))
+	}
+	return testRIFF(chunks.Bytes())
+}
+
+// testRIFF returns a webp riff container of the chunks.
+func testRIFF(chunks []byte) []byte {
+	buf := bytes.NewBufferString("RIFF")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(4+len(chunks)))
+	buf.WriteString("WEBP")
+	buf.Write(chunks)
+	return buf.Bytes()
+}
+
+// testWebPChunks returns the image chunks of the webp file, without the VP8X
+// chunk.
+func testWebPChunks(t *testing.T, pathName string) []byte {
+	t.Helper()
+	buf, err := os.ReadFile(pathName)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	chunks := new(bytes.Buffer)
+	if err := riffChunks(buf[12:], func(typ string, data []byte) error {
+		if typ != "VP8X" {
+			writeRIFFChunk(chunks, typ, data)
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	return chunks.Bytes()
+}
+
+// testWebPImage decodes the webp file.
+func testWebPImage(t *testing.T, pathName string) image.Image {
+	t.Helper()
+	f, err := os.Open(pathName)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer f.Close()
+	img, err := webp.Decode(f)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	return img
+}
+
+// testNRGBA returns the color of the image's pixel, relative to its bounds.
+func testNRGBA(img image.Image, x, y int) color.NRGBA {
+	b := img.Bounds()
+	return color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+}
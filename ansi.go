@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"time"
+)
+
+// encode encodes the image to w, using terminal graphics or ansi text.
+func (args *Args) encode(w io.Writer, img image.Image) error {
+	switch {
+	case args.ANSI:
+		return args.encodeANSI(w, img)
+	case args.images != nil:
+		return args.encodeKitty(w, img)
+	case args.mux != "":
+		return args.encodeMux(w, img)
+	}
+	return args.protocol.Encode(w, img)
+}
+
+// encodeANSI encodes the image to w as ansi colored text, using half block
+// characters (1x2 pixels per cell) or braille characters (2x4 pixels per
+// cell). Uses 24-bit color when supported by the terminal, and otherwise the
+// 256 color palette.
+func (args *Args) encodeANSI(w io.Writer, img image.Image) error {
+	start := time.Now()
+	e := &ansiEncoder{
+		w:         bufio.NewWriter(w),
+		src:       toNRGBA(img),
+		truecolor: ansiTruecolor(),
+	}
+	if args.Braille {
+		e.braille()
+	} else {
+		e.halfBlocks()
+	}
+	if err := e.w.Flush(); err != nil {
+		return err
+	}
+	args.logger("ansi encode: %v", time.Since(start))
+	return nil
+}
+
+// ansiEncoder encodes images as ansi colored text.
+type ansiEncoder struct {
+	w         *bufio.Writer
+	src       *image.NRGBA
+	truecolor bool
+	fg, bg    string
+}
+
+// halfBlocks writes the image using upper and lower half block characters,
+// with the foreground color as the top pixel and the background color as the
+// bottom pixel. Transparent pixels use the terminal's default colors.
+func (e *ansiEncoder) halfBlocks() {
+	b := e.src.Bounds()
+	for y := 0; y < b.Dy(); y += 2 {
+		for x := range b.Dx() {
+			top, bottom := e.at(x, y), e.at(x, y+1)
+			switch {
+			case top.A == 0 && bottom.A == 0:
+				e.color("", "")
+				e.w.WriteByte(' ')
+			case top.A == 0:
+				e.color(e.sgr(bottom, true), "")
+				e.w.WriteString("▄")
+			case bottom.A == 0:
+				e.color(e.sgr(top, true), "")
+				e.w.WriteString("▀")
+			default:
+				e.color(e.sgr(top, true), e.sgr(bottom, false))
+				e.w.WriteString("▀")
+			}
+		}
+		e.color("", "")
+		e.w.WriteByte('\n')
+	}
+}
+
+// braille writes the image using braille characters, raising the dots of
+// the pixels at least as bright as the cell's average, colored with the
+// average color of the raised dots.
+func (e *ansiEncoder) braille() {
+	b := e.src.Bounds()
+	for y := 0; y < b.Dy(); y += 4 {
+		for x := 0; x < b.Dx(); x += 2 {
+			var pixels [8]color.NRGBA
+			var lum [8]int
+			var total, n int
+			for i, d := range brailleDots {
+				if c := e.at(x+d.X, y+d.Y); c.A != 0 {
+					pixels[i], lum[i] = c, luminance(c)
+					total, n = total+lum[i], n+1
+				}
+			}
+			if n == 0 {
+				e.color("", "")
+				e.w.WriteByte(' ')
+				continue
+			}
+			var dots rune
+			var r, g, bl, count int
+			for i, c := range pixels {
+				if c.A == 0 || lum[i]*n < total {
+					continue
+				}
+				dots |= 1 << i
+				r, g, bl, count = r+int(c.R), g+int(c.G), bl+int(c.B), count+1
+			}
+			c := color.NRGBA{uint8(r / count), uint8(g / count), uint8(bl / count), 0xff}
+			e.color(e.sgr(c, true), "")
+			e.w.WriteRune(0x2800 + dots)
+		}
+		e.color("", "")
+		e.w.WriteByte('\n')
+	}
+}
+
+// at returns the pixel at x, y, or transparent when out of bounds. Pixels
+// that are mostly transparent are transparent.
+func (e *ansiEncoder) at(x, y int) color.NRGBA {
+	if !(image.Point{x, y}.In(e.src.Rect)) {
+		return color.NRGBA{}
+	}
+	c := e.src.NRGBAAt(x, y)
+	if c.A < 0x80 {
+		return color.NRGBA{}
+	}
+	return c
+}
+
+// color sets the foreground and background colors, when changed. An empty
+// color is the terminal's default color.
+func (e *ansiEncoder) color(fg, bg string) {
+	if fg == e.fg && bg == e.bg {
+		return
+	}
+	if (fg == "" && e.fg != "") || (bg == "" && e.bg != "") {
+		e.w.WriteString("\x1b[0m")
+		e.fg, e.bg = "", ""
+	}
+	if fg != e.fg {
+		e.w.WriteString(fg)
+	}
+	if bg != e.bg {
+		e.w.WriteString(bg)
+	}
+	e.fg, e.bg = fg, bg
+}
+
+// sgr returns the select graphic rendition sequence setting the foreground
+// or background color.
+func (e *ansiEncoder) sgr(c color.NRGBA, fg bool) string {
+	typ := 38
+	if !fg {
+		typ = 48
+	}
+	if e.truecolor {
+		return fmt.Sprintf("\x1b[%d;2;%d;%d;%dm", typ, c.R, c.G, c.B)
+	}
+	return fmt.Sprintf("\x1b[%d;5;%dm", typ, ansi256(c))
+}
+
+// ansi256 returns the closest color in the xterm 256 color palette's color
+// cube or grayscale ramp.
+func ansi256(c color.NRGBA) int {
+	// color cube
+	cube := func(v uint8) int {
+		switch {
+		case v < 48:
+			return 0
+		case v < 115:
+			return 1
+		}
+		return (int(v) - 35) / 40
+	}
+	r, g, b := cube(c.R), cube(c.G), cube(c.B)
+	cr, cg, cb := cubeLevels[r], cubeLevels[g], cubeLevels[b]
+	// grayscale ramp
+	avg := (int(c.R) + int(c.G) + int(c.B)) / 3
+	gray := min(max((avg-3)/10, 0), 23)
+	gv := 8 + 10*gray
+	if distance(c, gv, gv, gv) < distance(c, cr, cg, cb) {
+		return 232 + gray
+	}
+	return 16 + 36*r + 6*g + b
+}
+
+// distance returns the squared distance between the colors.
+func distance(c color.NRGBA, r, g, b int) int {
+	dr, dg, db := int(c.R)-r, int(c.G)-g, int(c.B)-b
+	return dr*dr + dg*dg + db*db
+}
+
+// luminance returns the relative luminance of the color (0-255).
+func luminance(c color.NRGBA) int {
+	return (299*int(c.R) + 587*int(c.G) + 114*int(c.B)) / 1000
+}
+
+// ansiTruecolor returns true when the terminal supports 24-bit color.
+func ansiTruecolor() bool {
+	switch os.Getenv("COLORTERM") {
+	case "truecolor", "24bit":
+		return true
+	}
+	return false
+}
+
+// ansiCellSize returns the number of pixels drawn per cell.
+func (args *Args) ansiCellSize() (int, int) {
+	if args.Braille {
+		return 2, 4
+	}
+	return 1, 2
+}
+
+// cubeLevels are the xterm 256 color palette's color cube levels.
+var cubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+// brailleDots are the pixel offsets of the braille dots, in bit order.
+var brailleDots = [8]image.Point{
+	{0, 0}, {0, 1}, {0, 2}, {1, 0}, {1, 1}, {1, 2}, {0, 3}, {1, 3},
+}
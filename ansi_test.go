@@ -0,0 +1,30 @@
+package main
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestAnsi256(t *testing.T) {
+	tests := []struct {
+		c   color.NRGBA
+		exp int
+	}{
+		{color.NRGBA{0, 0, 0, 0xff}, 16},
+		{color.NRGBA{0xff, 0xff, 0xff, 0xff}, 231},
+		{color.NRGBA{0xff, 0, 0, 0xff}, 196},
+		{color.NRGBA{0, 0xff, 0, 0xff}, 46},
+		{color.NRGBA{0, 0, 0xff, 0xff}, 21},
+		{color.NRGBA{95, 135, 175, 0xff}, 67},
+		{color.NRGBA{100, 140, 170, 0xff}, 67},
+		{color.NRGBA{8, 8, 8, 0xff}, 232},
+		{color.NRGBA{128, 128, 128, 0xff}, 244},
+		{color.NRGBA{238, 238, 238, 0xff}, 255},
+		{color.NRGBA{0, 48, 114, 0xff}, 23},
+	}
+	for i, test := range tests {
+		if v := ansi256(test.c); v != test.exp {
+			t.Errorf("test %d %v expected %d, got: %d", i, test.c, test.exp, v)
+		}
+	}
+}
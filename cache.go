@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cacheKey returns the render cache key for the file decoded by the decoder,
+// or empty when the result should not be cached. The key includes the file's
+// identity and the arguments that affect rendering. Spooled files are
+// identified by their content, other files by their path, size and
+// modification time.
+func (args *Args) cacheKey(pathName, mime string) string {
+	switch args.decoderName {
+	case "", "builtin", "resvg", "tag":
+		return ""
+	}
+	if args.NoCache || args.Play {
+		return ""
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n", version, args.decoderName, mime)
+	if p := args.plugin(mime, fileExt(pathName)); p != nil {
+		fmt.Fprintf(h, "%q\n", p.command)
+	}
+	if slices.Contains(args.temps, pathName) {
+		f, err := os.Open(pathName)
+		if err != nil {
+			return ""
+		}
+		defer f.Close()
+		if _, err := io.Copy(h, f); err != nil {
+			return ""
+		}
+	} else {
+		abs, err := filepath.Abs(pathName)
+		if err != nil {
+			return ""
+		}
+		fi, err := os.Stat(abs)
+		if err != nil {
+			return ""
+		}
+		fmt.Fprintf(h, "%s\n%d\n%d\n", abs, fi.Size(), fi.ModTime().UnixNano())
+	}
+	fmt.Fprintf(
+		h,
+		"%d %d %d %d %d %d %v %v %d\n",
+		args.Page, args.DPI, args.Width, args.Height, args.MinWidth, args.MinHeight,
+		args.Fg, args.Bg, args.Border,
+	)
+	fmt.Fprintf(
+		h,
+		"%d %v %v %v %v %d %d\n",
+		args.FontSize, args.FontStyle, args.FontVariant, args.FontFg, args.FontBg,
+		args.FontDPI, args.FontMargin,
+	)
+	fmt.Fprintf(
+		h,
+		"%d %d %d %v %v %t\n",
+		args.TimeCode.Duration(), args.Storyboard, args.GridCols,
+		args.MermaidIcons, args.MermaidBg, args.NoAutorotate,
+	)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cacheGet returns the cached image for the key, setting the page count.
+func (args *Args) cacheGet(key string) (image.Image, bool) {
+	dir, err := cacheDir()
+	if key == "" || err != nil {
+		return nil, false
+	}
+	start := time.Now()
+	pathName := filepath.Join(dir, key+cacheExt)
+	f, err := os.Open(pathName)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, false
+	}
+	pages, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil {
+		return nil, false
+	}
+	img, err := png.Decode(r)
+	if err != nil {
+		args.logger("cache: %s: %v", key, err)
+		return nil, false
+	}
+	// mark recently used
+	now := time.Now()
+	_ = os.Chtimes(pathName, now, now)
+	args.pages = pages
+	args.logger("cache hit: %s: %v", key, time.Since(start))
+	return img, true
+}
+
+// cachePut stores the image in the cache, evicting the least recently used
+// entries when the cache exceeds its maximum size. Images of files that
+// required a password are not stored, as they would be readable without it.
+func (args *Args) cachePut(key string, img image.Image) {
+	switch img.(type) {
+	case *animation, *video:
+		return
+	}
+	if args.encrypted {
+		args.logger("cache: %s: skipping encrypted file", key)
+		return
+	}
+	dir, err := cacheDir()
+	if key == "" || err != nil {
+		return
+	}
+	start := time.Now()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		args.logger("cache: %v", err)
+		return
+	}
+	buf := new(bytes.Buffer)
+	fmt.Fprintln(buf, args.pages)
+	enc := &png.Encoder{CompressionLevel: png.BestSpeed}
+	if err := enc.Encode(buf, img); err != nil {
+		args.logger("cache: %s: %v", key, err)
+		return
+	}
+	// write and rename, as another job may be reading the entry
+	f, err := os.CreateTemp(dir, key+".*")
+	if err != nil {
+		args.logger("cache: %v", err)
+		return
+	}
+	_, err = f.Write(buf.Bytes())
+	if err := f.Close(); err != nil {
+		args.logger("cache: %v", err)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(dir, key+cacheExt))
+	}
+	if err != nil {
+		args.logger("cache: %v", err)
+		_ = os.Remove(f.Name())
+		return
+	}
+	args.logger("cache put: %s: %v", key, time.Since(start))
+	args.cacheEvict(dir)
+}
+
+// cacheEvict removes the least recently used cache entries until the cache
+// is within its maximum size.
+func (args *Args) cacheEvict(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	type entry struct {
+		name string
+		size int64
+		mod  time.Time
+	}
+	var files []entry
+	var total int64
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != cacheExt {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, entry{e.Name(), fi.Size(), fi.ModTime()})
+		total += fi.Size()
+	}
+	slices.SortFunc(files, func(a, b entry) int {
+		return a.mod.Compare(b.mod)
+	})
+	for maxSize := int64(args.CacheSize) << 20; total > maxSize && len(files) != 0; files = files[1:] {
+		args.logger("cache evict: %s", files[0].name)
+		if err := os.Remove(filepath.Join(dir, files[0].name)); err != nil {
+			args.logger("cache: %v", err)
+		}
+		total -= files[0].size
+	}
+}
+
+// clearCache removes the render cache.
+func clearCache() error {
+	dir, err := cacheDir()
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// cacheDir returns the render cache directory.
+func cacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// cacheExt is the extension of render cache entries.
+const cacheExt = ".iv"
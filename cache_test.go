@@ -0,0 +1,82 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCacheKey(t *testing.T) {
+	pathName := filepath.Join(t.TempDir(), "a.pdf")
+	if err := os.WriteFile(pathName, []byte("%PDF-"), 0o644); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	base := (&Args{decoderName: "vipspdf"}).cacheKey(pathName, "application/pdf")
+	if base == "" {
+		t.Fatalf("expected key")
+	}
+	tests := []struct {
+		args  *Args
+		empty bool
+		same  bool
+	}{
+		{&Args{decoderName: "vipspdf"}, false, true},
+		{&Args{decoderName: ""}, true, false},
+		{&Args{decoderName: "builtin"}, true, false},
+		{&Args{decoderName: "resvg"}, true, false},
+		{&Args{decoderName: "tag"}, true, false},
+		{&Args{decoderName: "vipspdf", NoCache: true}, true, false},
+		{&Args{decoderName: "vipspdf", Play: true}, true, false},
+		{&Args{decoderName: "fitz"}, false, false},
+		{&Args{decoderName: "vipspdf", Page: 2}, false, false},
+		{&Args{decoderName: "vipspdf", DPI: 72}, false, false},
+		{&Args{decoderName: "vipspdf", Width: 100}, false, false},
+	}
+	for i, test := range tests {
+		key := test.args.cacheKey(pathName, "application/pdf")
+		switch {
+		case test.empty && key != "":
+			t.Errorf("test %d expected empty key, got: %q", i, key)
+		case !test.empty && key == "":
+			t.Errorf("test %d expected key", i)
+		case !test.empty && (key == base) != test.same:
+			t.Errorf("test %d expected same key %t, got: %q", i, test.same, key)
+		}
+	}
+}
+
+func TestCachePutGet(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("LocalAppData", dir)
+	img := testImage(3, 2, func(x, y int) color.NRGBA { return color.NRGBA{uint8(x), uint8(y), 0, 0xff} })
+	tests := []struct {
+		key       string
+		img       image.Image
+		encrypted bool
+		exp       bool
+	}{
+		{"a", img, false, true},
+		{"", img, false, false},
+		{"b", img, true, false},
+		{"c", &animation{Image: img}, false, false},
+	}
+	for i, test := range tests {
+		args := &Args{logger: t.Logf, CacheSize: 1, pages: 5, encrypted: test.encrypted}
+		args.cachePut(test.key, test.img)
+		args = &Args{logger: t.Logf}
+		got, ok := args.cacheGet(test.key)
+		switch {
+		case ok != test.exp:
+			t.Errorf("test %d expected cached %t, got: %t", i, test.exp, ok)
+		case !ok:
+		case got.Bounds() != img.Bounds() || args.pages != 5:
+			t.Errorf("test %d expected %v with 5 pages, got: %v with %d pages", i, img.Bounds(), got.Bounds(), args.pages)
+		case color.NRGBAModel.Convert(got.At(2, 1)) != img.At(2, 1):
+			t.Errorf("test %d expected %v, got: %v", i, img.At(2, 1), got.At(2, 1))
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/kenshaw/colors"
+	"github.com/xo/ox"
+)
+
+// config is the user configuration, containing option defaults set globally,
+// and per mime type and file extension, and the external decoder plugins.
+type config struct {
+	global  map[string][]string
+	mime    map[string]map[string][]string
+	ext     map[string]map[string][]string
+	plugin  map[string]map[string][]string
+	plugins []*plugin
+}
+
+// loadConfig loads the user configuration and the IV_* environment variables,
+// setting the options not set on the command line. Options set on the
+// command line take precedence over environment variables, which take
+// precedence over the configuration.
+func (args *Args) loadConfig(ctx context.Context) error {
+	args.fixed = make(map[string]bool)
+	if c, ok := ox.Ctx(ctx); ok {
+		for name, v := range c.Vars {
+			if v.WasSet() {
+				args.fixed[name] = true
+			}
+		}
+	}
+	args.config = new(config)
+	if pathName, err := configPath(); err == nil {
+		switch c, err := readConfig(pathName); {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return err
+		default:
+			if err := args.setOptions(c.global, false); err != nil {
+				return fmt.Errorf("config %s: %w", pathName, err)
+			}
+			args.config = c
+		}
+	}
+	// environment
+	env, typ := make(map[string][]string), reflect.TypeFor[Args]()
+	for name, i := range optionFields() {
+		s, ok := os.LookupEnv("IV_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")))
+		switch {
+		case !ok:
+		case typ.Field(i).Type.Kind() == reflect.Slice:
+			env[name] = strings.Split(s, ",")
+		default:
+			env[name] = []string{s}
+		}
+	}
+	if err := args.setOptions(env, false); err != nil {
+		return fmt.Errorf("environment: %w", err)
+	}
+	for name := range env {
+		args.fixed[name] = true
+	}
+	args.initColors()
+	return nil
+}
+
+// applyConfig applies the configured options for the mime type and file
+// extension, until reset by resetConfig. Extension options take precedence
+// over mime type options.
+func (args *Args) applyConfig(mime, ext string) error {
+	if args.config == nil {
+		return nil
+	}
+	var tables []map[string][]string
+	for _, pattern := range slices.Sorted(maps.Keys(args.config.mime)) {
+		if ok, _ := path.Match(pattern, mime); ok {
+			tables = append(tables, args.config.mime[pattern])
+		}
+	}
+	if opts, ok := args.config.ext[ext]; ok && ext != "" {
+		tables = append(tables, opts)
+	}
+	for _, opts := range tables {
+		if err := args.setOptions(opts, true); err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+	}
+	if len(tables) != 0 {
+		args.logger("config: %s (%s): %d tables", mime, ext, len(tables))
+		args.initColors()
+	}
+	return nil
+}
+
+// resetConfig restores the options changed by applyConfig.
+func (args *Args) resetConfig() {
+	if len(args.saved) == 0 {
+		return
+	}
+	v := reflect.ValueOf(args).Elem()
+	for i, prev := range args.saved {
+		v.Field(i).Set(prev)
+	}
+	args.saved = nil
+	args.initColors()
+}
+
+// setOptions sets the named options not fixed by a command-line flag or
+// environment variable. When save is true, the previous values are saved for
+// resetConfig.
+func (args *Args) setOptions(opts map[string][]string, save bool) error {
+	fields, v := optionFields(), reflect.ValueOf(args).Elem()
+	for _, name := range slices.Sorted(maps.Keys(opts)) {
+		i, ok := fields[name]
+		switch {
+		case !ok:
+			return fmt.Errorf("unknown option %q", name)
+		case args.fixed[name]:
+			continue
+		}
+		f := v.Field(i)
+		prev := reflect.New(f.Type()).Elem()
+		prev.Set(f)
+		if err := setOption(f, opts[name]); err != nil {
+			return fmt.Errorf("option %q: %w", name, err)
+		}
+		if !save {
+			continue
+		}
+		if args.saved == nil {
+			args.saved = make(map[int]reflect.Value)
+		}
+		if _, ok := args.saved[i]; !ok {
+			args.saved[i] = prev
+		}
+	}
+	return nil
+}
+
+// setOption parses and sets the values to the field.
+func setOption(f reflect.Value, values []string) error {
+	if f.Kind() == reflect.Slice {
+		if f.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", f.Type())
+		}
+		f.Set(reflect.ValueOf(slices.Clone(values)).Convert(f.Type()))
+		return nil
+	}
+	if len(values) != 1 {
+		return errors.New("expected a single value")
+	}
+	s := values[0]
+	switch p := f.Addr().Interface().(type) {
+	case **colors.Color:
+		c, err := colors.Parse(s)
+		if err != nil {
+			return err
+		}
+		*p = &c
+		return nil
+	case **duration:
+		d := new(duration)
+		if err := d.UnmarshalText([]byte(s)); err != nil {
+			return err
+		}
+		*p = d
+		return nil
+	}
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 0, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(s, 0, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(s, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetFloat(v)
+	default:
+		return fmt.Errorf("unsupported type %s", f.Type())
+	}
+	return nil
+}
+
+// optionFields returns the Args field index of each option, by flag name.
+var optionFields = sync.OnceValue(func() map[string]int {
+	fields, typ := make(map[string]int), reflect.TypeFor[Args]()
+	for i := range typ.NumField() {
+		f := typ.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := ox.DefaultFlagNameMapper(f.Name)
+		for opt := range strings.SplitSeq(f.Tag.Get("ox"), ",") {
+			if s, ok := strings.CutPrefix(opt, "name:"); ok {
+				name = s
+			}
+		}
+		fields[name] = i
+	}
+	return fields
+})
+
+// configPath returns the path of the user configuration file.
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name, "config.toml"), nil
+}
+
+// readConfig reads a toml configuration file, containing global options,
+// [mime."type"], [ext.name], and [plugin.name] tables.
+func readConfig(pathName string) (*config, error) {
+	buf, err := os.ReadFile(pathName)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if _, err := toml.Decode(string(buf), &m); err != nil {
+		return nil, fmt.Errorf("config %s: %w", pathName, err)
+	}
+	c := &config{
+		global: make(map[string][]string),
+		mime:   make(map[string]map[string][]string),
+		ext:    make(map[string]map[string][]string),
+		plugin: make(map[string]map[string][]string),
+	}
+	for key, value := range m {
+		tables, ok := map[string]map[string]map[string][]string{
+			"mime":   c.mime,
+			"ext":    c.ext,
+			"plugin": c.plugin,
+		}[key]
+		t, isTable := value.(map[string]any)
+		switch {
+		case ok && isTable:
+			for name, value := range t {
+				opts, ok := value.(map[string]any)
+				if !ok {
+					return nil, fmt.Errorf("config %s: invalid table %s.%s", pathName, key, name)
+				}
+				if key == "ext" {
+					name = strings.ToLower(strings.TrimPrefix(name, "."))
+				}
+				if tables[name] == nil {
+					tables[name] = make(map[string][]string)
+				}
+				if err := tomlOptions(tables[name], opts); err != nil {
+					return nil, fmt.Errorf("config %s: %s.%s: %w", pathName, key, name, err)
+				}
+			}
+		case isTable:
+			return nil, fmt.Errorf("config %s: invalid table %s: expected mime, ext, or plugin", pathName, key)
+		default:
+			if err := tomlOptions(c.global, map[string]any{key: value}); err != nil {
+				return nil, fmt.Errorf("config %s: %w", pathName, err)
+			}
+		}
+	}
+	// check option names
+	fields := optionFields()
+	tables := slices.Concat(
+		[]map[string][]string{c.global},
+		slices.Collect(maps.Values(c.mime)),
+		slices.Collect(maps.Values(c.ext)),
+	)
+	for _, opts := range tables {
+		for key := range opts {
+			if _, ok := fields[key]; !ok {
+				return nil, fmt.Errorf("config %s: unknown option %q", pathName, key)
+			}
+		}
+	}
+	// build plugins
+	for _, name := range slices.Sorted(maps.Keys(c.plugin)) {
+		p, err := newPlugin(name, c.plugin[name])
+		if err != nil {
+			return nil, fmt.Errorf("config %s: %w", pathName, err)
+		}
+		c.plugins = append(c.plugins, p)
+	}
+	return c, nil
+}
+
+// tomlOptions adds the decoded toml values to the options table. Values are
+// strings, numbers, booleans, or arrays of those.
+func tomlOptions(table map[string][]string, opts map[string]any) error {
+	for key, value := range opts {
+		values, ok := value.([]any)
+		if !ok {
+			values = []any{value}
+		}
+		table[key] = []string{}
+		for _, v := range values {
+			var s string
+			switch x := v.(type) {
+			case string:
+				s = x
+			case bool:
+				s = strconv.FormatBool(x)
+			case int64:
+				s = strconv.FormatInt(x, 10)
+			case float64:
+				s = strconv.FormatFloat(x, 'g', -1, 64)
+			default:
+				return fmt.Errorf("%s: invalid value %v", key, v)
+			}
+			table[key] = append(table[key], s)
+		}
+	}
+	return nil
+}
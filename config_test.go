@@ -0,0 +1,216 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/kenshaw/colors"
+)
+
+func TestReadConfig(t *testing.T) {
+	tests := []struct {
+		s      string
+		global map[string][]string
+		mime   map[string]map[string][]string
+		ext    map[string]map[string][]string
+		err    bool
+	}{
+		{"", map[string][]string{}, nil, nil, false},
+		{
+			"# comment\nbg = \"black\" # trailing\ndpi = 1_50\nrotate = 1.5\nloop = true\n",
+			map[string][]string{"bg": {"black"}, "dpi": {"150"}, "rotate": {"1.5"}, "loop": {"true"}},
+			nil, nil, false,
+		},
+		{
+			"mermaid-icons = [\n  \"logos\", # comment\n  'mdi',\n]\ninclude = []\n",
+			map[string][]string{"mermaid-icons": {"logos", "mdi"}, "include": {}},
+			nil, nil, false,
+		},
+		{
+			"bg = \"black\"\n[mime.\"image/svg\"]\nbg = \"white\"\n[mime.\"video/*\"]\nstoryboard = 4\n",
+			map[string][]string{"bg": {"black"}},
+			map[string]map[string][]string{"image/svg": {"bg": {"white"}}, "video/*": {"storyboard": {"4"}}},
+			nil, false,
+		},
+		{
+			"[ext.pdf]\ndpi = 150\n[ext.\".EPUB\"]\ndpi = 100\n",
+			map[string][]string{},
+			nil,
+			map[string]map[string][]string{"pdf": {"dpi": {"150"}}, "epub": {"dpi": {"100"}}},
+			false,
+		},
+		{
+			"mime.\"image/png\".bg = 'red'\n",
+			map[string][]string{},
+			map[string]map[string][]string{"image/png": {"bg": {"red"}}},
+			nil, false,
+		},
+		{"bg = \"black\"\nbg = \"white\"\n", nil, nil, nil, true},
+		{"nope = 1\n", nil, nil, nil, true},
+		{"[mime.\"image/png\"]\nnope = 1\n", nil, nil, nil, true},
+		{"[bogus]\nbg = \"black\"\n", nil, nil, nil, true},
+		{"[mime]\nbg = \"black\"\n", nil, nil, nil, true},
+		{"bg = \"black\n", nil, nil, nil, true},
+		{"bg = [\n", nil, nil, nil, true},
+		{"bg = 1979-05-27\n", nil, nil, nil, true},
+		{"include = [[\"a\"]]\n", nil, nil, nil, true},
+		{"bg = { a = 1 }\n", nil, nil, nil, true},
+	}
+	for i, test := range tests {
+		pathName := filepath.Join(t.TempDir(), "config.toml")
+		if err := os.WriteFile(pathName, []byte(test.s), 0o644); err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		c, err := readConfig(pathName)
+		switch {
+		case test.err && err == nil:
+			t.Errorf("test %d expected error, got: %v", i, c)
+			continue
+		case test.err:
+			continue
+		case err != nil:
+			t.Errorf("test %d expected no error, got: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(c.global, test.global) {
+			t.Errorf("test %d expected global %v, got: %v", i, test.global, c.global)
+		}
+		if test.mime == nil {
+			test.mime = map[string]map[string][]string{}
+		}
+		if !reflect.DeepEqual(c.mime, test.mime) {
+			t.Errorf("test %d expected mime %v, got: %v", i, test.mime, c.mime)
+		}
+		if test.ext == nil {
+			test.ext = map[string]map[string][]string{}
+		}
+		if !reflect.DeepEqual(c.ext, test.ext) {
+			t.Errorf("test %d expected ext %v, got: %v", i, test.ext, c.ext)
+		}
+	}
+}
+
+func TestReadConfigPlugins(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp []*plugin
+		err bool
+	}{
+		{
+			"[plugin.foo]\next = [\".FOO\"]\ncommand = [\"foo2png\", \"--dpi\", \"{dpi}\", \"{path}\"]\n" +
+				"[plugin.bar]\nmime = [\"application/x-bar\"]\ncommand = \"bar2svg --stdin\"\n",
+			[]*plugin{
+				{name: "bar", mime: []string{"application/x-bar"}, command: []string{"bar2svg", "--stdin"}},
+				{name: "foo", ext: []string{"foo"}, command: []string{"foo2png", "--dpi", "{dpi}", "{path}"}},
+			},
+			false,
+		},
+		{"[plugin.foo]\next = [\"foo\"]\n", nil, true},
+		{"[plugin.foo]\ncommand = \"foo\"\n", nil, true},
+		{"[plugin.foo]\next = [\"foo\"]\ncommand = \"foo\"\nnope = 1\n", nil, true},
+	}
+	for i, test := range tests {
+		pathName := filepath.Join(t.TempDir(), "config.toml")
+		if err := os.WriteFile(pathName, []byte(test.s), 0o644); err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		c, err := readConfig(pathName)
+		switch {
+		case test.err && err == nil:
+			t.Errorf("test %d expected error, got: %v", i, c.plugins)
+		case !test.err && err != nil:
+			t.Errorf("test %d expected no error, got: %v", i, err)
+		case err == nil && !reflect.DeepEqual(c.plugins, test.exp):
+			t.Errorf("test %d expected %v, got: %v", i, test.exp, c.plugins)
+		}
+	}
+}
+
+func TestSetOptions(t *testing.T) {
+	bg, err := colors.Parse("black")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	args := &Args{Zoom: 1, Bg: &bg, MermaidBg: &bg, fixed: map[string]bool{"rotate": true}}
+	opts := map[string][]string{
+		"zoom":    {"2.5"},
+		"rotate":  {"90"},
+		"include": {"*.png", "*.jpg"},
+		"loop":    {"true"},
+		"dpi":     {"0x20"},
+	}
+	if err := args.setOptions(opts, true); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	switch {
+	case args.Zoom != 2.5:
+		t.Errorf("expected zoom 2.5, got: %g", args.Zoom)
+	case args.Rotate != 0:
+		t.Errorf("expected fixed rotate 0, got: %g", args.Rotate)
+	case !slices.Equal(args.Include, []string{"*.png", "*.jpg"}):
+		t.Errorf("expected include, got: %q", args.Include)
+	case !args.Loop:
+		t.Errorf("expected loop, got: %t", args.Loop)
+	case args.DPI != 32:
+		t.Errorf("expected dpi 32, got: %d", args.DPI)
+	}
+	if n := len(args.saved); n != 4 {
+		t.Errorf("expected 4 saved options, got: %d %v", n, slices.Sorted(maps.Keys(args.saved)))
+	}
+	args.resetConfig()
+	if args.Zoom != 1 || args.Include != nil || args.Loop || args.DPI != 0 {
+		t.Errorf("expected reset options, got: %g %q %t %d", args.Zoom, args.Include, args.Loop, args.DPI)
+	}
+	for _, opts := range []map[string][]string{
+		{"nope": {"1"}},
+		{"zoom": {"a"}},
+		{"zoom": {"1", "2"}},
+		{"loop": {"maybe"}},
+		{"dpi": {"-1"}},
+		{"bg": {"notacolor"}},
+		{"play-limit": {"1x"}},
+	} {
+		if err := args.setOptions(opts, false); err == nil {
+			t.Errorf("%v expected error", opts)
+		}
+	}
+}
+
+func TestApplyConfigPdfDpi(t *testing.T) {
+	bg, err := colors.Parse("black")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	c := &config{
+		mime: map[string]map[string][]string{"application/*": {"dpi": {"100"}}},
+		ext:  map[string]map[string][]string{"pdf": {"dpi": {"150"}}},
+	}
+	tests := []struct {
+		mime  string
+		ext   string
+		fixed bool
+		exp   float64
+	}{
+		{"application/pdf", "pdf", false, 150},
+		{"application/pdf", "", false, 100},
+		{"image/png", "png", false, 0},
+		{"application/pdf", "pdf", true, 0},
+	}
+	for i, test := range tests {
+		args := &Args{logger: t.Logf, Zoom: 1, Bg: &bg, MermaidBg: &bg, config: c, fixed: map[string]bool{"dpi": test.fixed}}
+		if err := args.applyConfig(test.mime, test.ext); err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if opts := args.pdfloadOptions(nil); opts.Dpi != test.exp {
+			t.Errorf("test %d expected dpi %g, got: %g", i, test.exp, opts.Dpi)
+		}
+		args.resetConfig()
+		if opts := args.pdfloadOptions(nil); opts.Dpi != 0 {
+			t.Errorf("test %d expected reset dpi 0, got: %g", i, opts.Dpi)
+		}
+	}
+}
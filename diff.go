@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"time"
+
+	"golang.org/x/image/draw"
+)
+
+// DiffArgs are the diff command's arguments.
+type DiffArgs struct {
+	Threshold float64 `ox:"maximum percentage of differing pixels"`
+	Fuzz      uint    `ox:"maximum channel difference of matching pixels (0-255)"`
+}
+
+// runDiff compares two images.
+func runDiff(w io.Writer, args *Args, diffArgs *DiffArgs) func(context.Context, []string) error {
+	return func(ctx context.Context, cliargs []string) error {
+		args.diff = diffArgs
+		return run(w, args)(ctx, cliargs)
+	}
+}
+
+// compare decodes and compares the targets, writing the differences to w.
+// When display is true, the images are displayed side by side, followed by a
+// heatmap of the differing pixels. Returns an error when the percentage of
+// differing pixels exceeds the threshold.
+func (args *Args) compare(w io.Writer, targets []target, display bool) error {
+	if len(targets) != 2 {
+		return errors.New("diff: requires two images")
+	}
+	defer args.removeTemps()
+	var imgs [2]*image.NRGBA
+	var views [2]image.Image
+	for i, v := range targets {
+		img, mime, err := args.decode(v)
+		if err != nil {
+			return fmt.Errorf("diff: %q: %w", v.path, err)
+		}
+		switch x := img.(type) {
+		case *animation:
+			img = x.Image
+		case *video:
+			img = x.Image
+		}
+		if img, err = args.transform(img); err != nil {
+			return fmt.Errorf("diff: %q: %w", v.path, err)
+		}
+		imgs[i], views[i] = toNRGBA(img), args.addBackground(mime, img)
+	}
+	args.resetConfig()
+	// align to the first image's size
+	if a, b := imgs[0].Rect, imgs[1].Rect; a != b {
+		args.logger("diff: scaling %dx%d to %dx%d", b.Dx(), b.Dy(), a.Dx(), a.Dy())
+		dst := image.NewNRGBA(a)
+		draw.CatmullRom.Scale(dst, a, imgs[1], b, draw.Src, nil)
+		imgs[1] = dst
+		view := image.NewNRGBA(a)
+		draw.CatmullRom.Scale(view, a, views[1], views[1].Bounds(), draw.Src, nil)
+		views[1] = view
+	}
+	start := time.Now()
+	d := diffImages(imgs[0], imgs[1], uint8(min(args.diff.Fuzz, 255)))
+	args.logger("diff: %v", time.Since(start))
+	// display
+	if display || args.Output != "" {
+		img := args.scale(sideBySide(views[0], views[1], d.heatmap))
+		if args.Output != "" {
+			if err := args.writeImage(args.Output, img); err != nil {
+				return err
+			}
+		} else if err := args.encode(w, img); err != nil {
+			return err
+		}
+	}
+	// statistics
+	pct := 100 * float64(d.count) / float64(max(d.total, 1))
+	psnr := "inf"
+	if d.mse != 0 {
+		psnr = fmt.Sprintf("%.2f dB", 10*math.Log10(255*255/d.mse))
+	}
+	fmt.Fprintf(w, "%s %s:\n", targets[0].path, targets[1].path)
+	field := func(name string, v any) {
+		fmt.Fprintf(w, "  %-12s %v\n", name+":", v)
+	}
+	field("dimensions", fmt.Sprintf("%dx%d", imgs[0].Rect.Dx(), imgs[0].Rect.Dy()))
+	field("different", fmt.Sprintf("%d pixels (%.2f%%)", d.count, pct))
+	field("psnr", psnr)
+	field("ssim", fmt.Sprintf("%.4f", d.ssim))
+	if pct > args.diff.Threshold {
+		return fmt.Errorf("diff: %.2f%% of pixels differ (threshold: %g%%)", pct, args.diff.Threshold)
+	}
+	return nil
+}
+
+// diffResult is the result of comparing two images.
+type diffResult struct {
+	// count is the number of differing pixels, of total pixels
+	count, total int
+	// mse is the mean squared error of the channels
+	mse  float64
+	ssim float64
+	// heatmap shows the differing pixels, colored from red to yellow by the
+	// difference, over a dimmed grayscale of the first image
+	heatmap *image.NRGBA
+}
+
+// diffImages compares two images of the same size. Pixels having channels
+// that differ by more than fuzz are counted as differing. Colors are compared
+// premultiplied by alpha, so that transparent pixels are the same regardless
+// of their color.
+func diffImages(a, b *image.NRGBA, fuzz uint8) *diffResult {
+	d := &diffResult{
+		total:   len(a.Pix) / 4,
+		heatmap: image.NewNRGBA(a.Rect),
+	}
+	var sum float64
+	for i := 0; i < len(a.Pix); i += 4 {
+		pa, pb := premultiply(a.Pix[i:i+4]), premultiply(b.Pix[i:i+4])
+		var m uint8
+		for j := range pa {
+			v := pa[j] - pb[j]
+			sum += float64(v * v)
+			m = max(m, uint8(abs(v)))
+		}
+		var c color.NRGBA
+		if m > fuzz {
+			d.count++
+			t := float64(m) / 255
+			c = color.NRGBA{uint8(128 + 127*min(2*t, 1)), uint8(255 * max(2*t-1, 0)), 0, 0xff}
+		} else {
+			v := uint8(luminance(color.NRGBA{a.Pix[i], a.Pix[i+1], a.Pix[i+2], a.Pix[i+3]}) * int(a.Pix[i+3]) / 255 / 4)
+			c = color.NRGBA{v, v, v, 0xff}
+		}
+		d.heatmap.Pix[i], d.heatmap.Pix[i+1], d.heatmap.Pix[i+2], d.heatmap.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	d.mse = sum / float64(max(len(a.Pix), 1))
+	d.ssim = ssim(a, b)
+	return d
+}
+
+// ssim returns the mean structural similarity of the images' luminance,
+// using 8x8 windows with a stride of 4.
+//
+// See: https://en.wikipedia.org/wiki/Structural_similarity_index_measure
+func ssim(a, b *image.NRGBA) float64 {
+	const size, stride = 8, 4
+	const c1, c2 = (0.01 * 255) * (0.01 * 255), (0.03 * 255) * (0.03 * 255)
+	w, h := a.Rect.Dx(), a.Rect.Dy()
+	lum := func(img *image.NRGBA, x, y int) float64 {
+		i := img.PixOffset(x, y)
+		c := color.NRGBA{img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3]}
+		return float64(luminance(c) * int(c.A) / 255)
+	}
+	var total float64
+	var n int
+	for y := 0; y == 0 || y+size <= h; y += stride {
+		for x := 0; x == 0 || x+size <= w; x += stride {
+			ww, wh := min(size, w-x), min(size, h-y)
+			var sa, sb, saa, sbb, sab float64
+			for wy := y; wy < y+wh; wy++ {
+				for wx := x; wx < x+ww; wx++ {
+					va, vb := lum(a, wx, wy), lum(b, wx, wy)
+					sa, sb = sa+va, sb+vb
+					saa, sbb, sab = saa+va*va, sbb+vb*vb, sab+va*vb
+				}
+			}
+			k := float64(ww * wh)
+			ma, mb := sa/k, sb/k
+			va, vb, cov := saa/k-ma*ma, sbb/k-mb*mb, sab/k-ma*mb
+			total += (2*ma*mb + c1) * (2*cov + c2) / ((ma*ma + mb*mb + c1) * (va + vb + c2))
+			n++
+		}
+	}
+	return total / float64(n)
+}
+
+// sideBySide composes the images in a single row.
+func sideBySide(imgs ...image.Image) image.Image {
+	const pad = 8
+	var width, height int
+	for _, img := range imgs {
+		b := img.Bounds()
+		width, height = width+b.Dx(), max(height, b.Dy())
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, width+pad*(len(imgs)-1), height))
+	x := 0
+	for _, img := range imgs {
+		b := img.Bounds()
+		draw.Draw(dst, image.Rect(x, 0, x+b.Dx(), b.Dy()), img, b.Min, draw.Src)
+		x += b.Dx() + pad
+	}
+	return dst
+}
+
+// premultiply returns the nrgba pixel's channels premultiplied by alpha.
+func premultiply(p []uint8) [4]int {
+	a := int(p[3])
+	return [4]int{int(p[0]) * a / 255, int(p[1]) * a / 255, int(p[2]) * a / 255, a}
+}
+
+// abs returns the absolute value of v.
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
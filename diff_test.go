@@ -0,0 +1,110 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestDiffImages(t *testing.T) {
+	gradient := testImage(16, 16, func(x, y int) color.NRGBA {
+		return color.NRGBA{uint8(x * 16), uint8(y * 16), 0x80, 0xff}
+	})
+	tests := []struct {
+		a, b  *image.NRGBA
+		fuzz  uint8
+		count int
+		ssim  float64
+	}{
+		{gradient, gradient, 0, 0, 1},
+		{
+			testImage(4, 4, func(int, int) color.NRGBA { return color.NRGBA{0xff, 0, 0, 0} }),
+			testImage(4, 4, func(int, int) color.NRGBA { return color.NRGBA{0, 0xff, 0, 0} }),
+			0, 0, 1,
+		},
+		{
+			testImage(16, 16, func(int, int) color.NRGBA { return color.NRGBA{0, 0, 0, 0xff} }),
+			testImage(16, 16, func(int, int) color.NRGBA { return color.NRGBA{0xff, 0xff, 0xff, 0xff} }),
+			0, 256, 0,
+		},
+		{
+			gradient,
+			testImage(16, 16, func(x, y int) color.NRGBA {
+				c := gradient.NRGBAAt(x, y)
+				if x < 4 {
+					c.B += 4
+				}
+				return c
+			}),
+			0, 64, -1,
+		},
+		{
+			gradient,
+			testImage(16, 16, func(x, y int) color.NRGBA {
+				c := gradient.NRGBAAt(x, y)
+				if x < 4 {
+					c.B += 4
+				}
+				return c
+			}),
+			4, 0, -1,
+		},
+	}
+	for i, test := range tests {
+		d := diffImages(test.a, test.b, test.fuzz)
+		if d.count != test.count || d.total != len(test.a.Pix)/4 {
+			t.Errorf("test %d expected %d of %d, got: %d of %d", i, test.count, len(test.a.Pix)/4, d.count, d.total)
+		}
+		if test.count == 0 && test.fuzz == 0 && d.mse != 0 {
+			t.Errorf("test %d expected mse 0, got: %g", i, d.mse)
+		}
+		if test.ssim != -1 && math.Abs(d.ssim-test.ssim) > 0.01 {
+			t.Errorf("test %d expected ssim %g, got: %g", i, test.ssim, d.ssim)
+		}
+		if d.ssim < -1 || d.ssim > 1 {
+			t.Errorf("test %d expected ssim in -1..1, got: %g", i, d.ssim)
+		}
+	}
+}
+
+func TestSSIM(t *testing.T) {
+	noise := func(x, y int) color.NRGBA {
+		v := uint8((x*7 + y*13) * 37)
+		return color.NRGBA{v, v, v, 0xff}
+	}
+	tests := []struct {
+		w, h   int
+		f      func(int, int) color.NRGBA
+		lo, hi float64
+	}{
+		// identical, including images smaller than the window
+		{32, 32, noise, 1, 1},
+		{3, 5, noise, 1, 1},
+		// brightened noise keeps its structure
+		{32, 32, func(x, y int) color.NRGBA {
+			c := noise(x, y)
+			v := uint8(min(int(c.R)+10, 0xff))
+			return color.NRGBA{v, v, v, 0xff}
+		}, 0.8, 0.999},
+		// flat gray loses the structure
+		{32, 32, func(int, int) color.NRGBA { return color.NRGBA{0x80, 0x80, 0x80, 0xff} }, -1, 0.2},
+	}
+	for i, test := range tests {
+		a, b := testImage(test.w, test.h, noise), testImage(test.w, test.h, test.f)
+		if v := ssim(a, b); v < test.lo-1e-9 || v > test.hi+1e-9 {
+			t.Errorf("test %d expected ssim in %g..%g, got: %g", i, test.lo, test.hi, v)
+		}
+	}
+}
+
+// testImage returns an image of the size with the pixel colors of f.
+func testImage(w, h int, f func(int, int) color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.SetNRGBA(x, y, f(x, y))
+		}
+	}
+	return img
+}
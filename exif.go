@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// autorotate rotates the image, or the frames of an animation, according to
+// the exif orientation in the encoded image buf.
+func (args *Args) autorotate(img image.Image, buf []byte) image.Image {
+	if args.NoAutorotate {
+		return img
+	}
+	o := exifOrientation(buf)
+	if o < 2 {
+		return img
+	}
+	start := time.Now()
+	if a, ok := img.(*animation); ok {
+		for i, frame := range a.frames {
+			a.frames[i] = orient(frame, o)
+		}
+		a.Image = a.frames[0]
+	} else {
+		img = orient(img, o)
+	}
+	args.logger("exif orientation %d: %v", o, time.Since(start))
+	return img
+}
+
+// exifOrientation returns the exif orientation (1-8) of the encoded jpeg,
+// tiff, or webp image in buf, or 0 when not present.
+func exifOrientation(buf []byte) int {
+	return tiffOrientation(exifData(buf))
+}
+
+// exifData returns the tiff encoded exif data of the encoded jpeg, tiff, or
+// webp image in buf, or nil when not present.
+func exifData(buf []byte) []byte {
+	switch {
+	case bytes.HasPrefix(buf, []byte{0xff, 0xd8}):
+		// jpeg segments, up to the start of scan
+		for i := 2; i+4 <= len(buf) && buf[i] == 0xff; {
+			marker, n := buf[i+1], int(binary.BigEndian.Uint16(buf[i+2:]))
+			if marker == 0xd9 || marker == 0xda || n < 2 || len(buf) < i+2+n {
+				break
+			}
+			if seg := buf[i+4 : i+2+n]; marker == 0xe1 && bytes.HasPrefix(seg, exifHeader) {
+				return seg[len(exifHeader):]
+			}
+			i += 2 + n
+		}
+	case bytes.HasPrefix(buf, []byte("II*\x00")), bytes.HasPrefix(buf, []byte("MM\x00*")):
+		return buf
+	case len(buf) >= 12 && string(buf[:4]) == "RIFF" && string(buf[8:12]) == "WEBP":
+		var data []byte
+		_ = riffChunks(buf[12:], func(typ string, b []byte) error {
+			if typ == "EXIF" {
+				data = bytes.TrimPrefix(b, exifHeader)
+			}
+			return nil
+		})
+		return data
+	}
+	return nil
+}
+
+// tiffOrientation returns the orientation tag from the first image file
+// directory of the tiff encoded exif data, or 0 when not present.
+func tiffOrientation(buf []byte) int {
+	order := tiffByteOrder(buf)
+	if order == nil {
+		return 0
+	}
+	i := int(order.Uint32(buf[4:]))
+	if i < 8 || len(buf) < i+2 {
+		return 0
+	}
+	n := int(order.Uint16(buf[i:]))
+	for i += 2; n > 0 && i+12 <= len(buf); n, i = n-1, i+12 {
+		if order.Uint16(buf[i:]) == 0x0112 {
+			if o := int(order.Uint16(buf[i+8:])); 1 <= o && o <= 8 {
+				return o
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// tiffByteOrder returns the byte order of the tiff encoded data, or nil when
+// buf is not tiff encoded.
+func tiffByteOrder(buf []byte) binary.ByteOrder {
+	switch {
+	case len(buf) < 8:
+		return nil
+	case string(buf[:2]) == "II":
+		return binary.LittleEndian
+	case string(buf[:2]) == "MM":
+		return binary.BigEndian
+	}
+	return nil
+}
+
+// parseEXIF parses the named fields of the exif data's image, exif, and gps
+// image file directories.
+func parseEXIF(buf []byte) map[string]string {
+	buf = bytes.TrimPrefix(buf, exifHeader)
+	order := tiffByteOrder(buf)
+	if order == nil {
+		return nil
+	}
+	fields := make(map[string]string)
+	var walk func(int, map[uint16]string, int)
+	walk = func(i int, names map[uint16]string, depth int) {
+		if i < 8 || len(buf) < i+2 || depth > 2 {
+			return
+		}
+		n := int(order.Uint16(buf[i:]))
+		for i += 2; n > 0 && i+12 <= len(buf); n, i = n-1, i+12 {
+			entry := buf[i : i+12]
+			switch tag := order.Uint16(entry); {
+			case tag == 0x8769 && depth == 0:
+				walk(int(order.Uint32(entry[8:])), exifTags, depth+1)
+			case tag == 0x8825 && depth == 0:
+				walk(int(order.Uint32(entry[8:])), gpsTags, depth+1)
+			case names[tag] != "":
+				if s := exifValue(buf, order, entry); s != "" {
+					fields[names[tag]] = s
+				}
+			}
+		}
+	}
+	walk(int(order.Uint32(buf[4:])), exifTags, 0)
+	return fields
+}
+
+// exifValue formats the value of the image file directory entry.
+func exifValue(buf []byte, order binary.ByteOrder, entry []byte) string {
+	typ, count := order.Uint16(entry[2:]), int(order.Uint32(entry[4:]))
+	var size int
+	switch typ {
+	case 1, 2, 6, 7: // byte, ascii, sbyte, undefined
+		size = 1
+	case 3, 8: // short, sshort
+		size = 2
+	case 4, 9: // long, slong
+		size = 4
+	case 5, 10: // rational, srational
+		size = 8
+	default:
+		return ""
+	}
+	if count <= 0 || count > 1<<16 {
+		return ""
+	}
+	data := entry[8:12]
+	if n := size * count; n > 4 {
+		i := int(order.Uint32(entry[8:]))
+		if i < 0 || len(buf) < i+n {
+			return ""
+		}
+		data = buf[i : i+n]
+	}
+	if typ == 2 || typ == 7 {
+		s := strings.TrimRight(string(data[:count]), "\x00 ")
+		for _, r := range s {
+			if r < 0x20 || 0x7e < r {
+				return ""
+			}
+		}
+		return s
+	}
+	var v []string
+	for i := range min(count, 16) {
+		b := data[i*size:]
+		switch typ {
+		case 1:
+			v = append(v, strconv.Itoa(int(b[0])))
+		case 6:
+			v = append(v, strconv.Itoa(int(int8(b[0]))))
+		case 3:
+			v = append(v, strconv.Itoa(int(order.Uint16(b))))
+		case 8:
+			v = append(v, strconv.Itoa(int(int16(order.Uint16(b)))))
+		case 4:
+			v = append(v, strconv.FormatUint(uint64(order.Uint32(b)), 10))
+		case 9:
+			v = append(v, strconv.Itoa(int(int32(order.Uint32(b)))))
+		case 5:
+			v = append(v, fmt.Sprintf("%d/%d", order.Uint32(b), order.Uint32(b[4:])))
+		case 10:
+			v = append(v, fmt.Sprintf("%d/%d", int32(order.Uint32(b)), int32(order.Uint32(b[4:]))))
+		}
+	}
+	return strings.Join(v, " ")
+}
+
+// orient transforms the image according to the exif orientation.
+func orient(img image.Image, o int) image.Image {
+	b := img.Bounds()
+	src, ok := img.(*image.NRGBA)
+	if !ok || b.Min != (image.Point{}) {
+		src = image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	}
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := range dh {
+		for x := range dw {
+			var sx, sy int
+			switch o {
+			case 2: // flip horizontal
+				sx, sy = w-1-x, y
+			case 3: // rotate 180
+				sx, sy = w-1-x, h-1-y
+			case 4: // flip vertical
+				sx, sy = x, h-1-y
+			case 5: // transpose
+				sx, sy = y, x
+			case 6: // rotate 90 clockwise
+				sx, sy = y, h-1-x
+			case 7: // transverse
+				sx, sy = w-1-y, h-1-x
+			case 8: // rotate 270 clockwise
+				sx, sy = w-1-y, x
+			default:
+				sx, sy = x, y
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):])
+		}
+	}
+	return dst
+}
+
+// exifHeader is the header of exif data in jpeg app1 segments.
+var exifHeader = []byte("Exif\x00\x00")
+
+// exifTags are the names of the image and exif image file directory tags.
+var exifTags = map[uint16]string{
+	0x010e: "ImageDescription",
+	0x010f: "Make",
+	0x0110: "Model",
+	0x0112: "Orientation",
+	0x011a: "XResolution",
+	0x011b: "YResolution",
+	0x0128: "ResolutionUnit",
+	0x0131: "Software",
+	0x0132: "DateTime",
+	0x013b: "Artist",
+	0x8298: "Copyright",
+	0x829a: "ExposureTime",
+	0x829d: "FNumber",
+	0x8822: "ExposureProgram",
+	0x8827: "ISOSpeedRatings",
+	0x9000: "ExifVersion",
+	0x9003: "DateTimeOriginal",
+	0x9004: "DateTimeDigitized",
+	0x9010: "OffsetTime",
+	0x9201: "ShutterSpeedValue",
+	0x9202: "ApertureValue",
+	0x9204: "ExposureBiasValue",
+	0x9207: "MeteringMode",
+	0x9209: "Flash",
+	0x920a: "FocalLength",
+	0xa001: "ColorSpace",
+	0xa002: "PixelXDimension",
+	0xa003: "PixelYDimension",
+	0xa402: "ExposureMode",
+	0xa403: "WhiteBalance",
+	0xa405: "FocalLengthIn35mmFilm",
+	0xa430: "CameraOwnerName",
+	0xa431: "BodySerialNumber",
+	0xa433: "LensMake",
+	0xa434: "LensModel",
+}
+
+// gpsTags are the names of the gps image file directory tags.
+var gpsTags = map[uint16]string{
+	0x0000: "GPSVersionID",
+	0x0001: "GPSLatitudeRef",
+	0x0002: "GPSLatitude",
+	0x0003: "GPSLongitudeRef",
+	0x0004: "GPSLongitude",
+	0x0005: "GPSAltitudeRef",
+	0x0006: "GPSAltitude",
+	0x0007: "GPSTimeStamp",
+	0x001d: "GPSDateStamp",
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"slices"
+	"testing"
+)
+
+func TestExifOrientation(t *testing.T) {
+	le, be := testTIFF(binary.LittleEndian, 6), testTIFF(binary.BigEndian, 8)
+	tests := []struct {
+		buf []byte
+		exp int
+	}{
+		{nil, 0},
+		{[]byte("not an image"), 0},
+		{le, 6},
+		{be, 8},
+		{testTIFF(binary.LittleEndian, 1), 1},
+		{testTIFF(binary.LittleEndian, 9), 0},
+		{testTIFF(binary.LittleEndian, 0), 0},
+		{le[:12], 0},
+		{testJPEG(le), 6},
+		{testJPEG(be), 8},
+		{testJPEG(le[:12]), 0},
+		{testJPEG(nil), 0},
+		{testWebP(append(slices.Clone(exifHeader), le...)), 6},
+		{testWebP(be), 8},
+		{testWebP(nil), 0},
+	}
+	for i, test := range tests {
+		if o := exifOrientation(test.buf); o != test.exp {
+			t.Errorf("test %d expected %d, got: %d", i, test.exp, o)
+		}
+	}
+}
+
+func TestOrient(t *testing.T) {
+	tests := []struct {
+		o   int
+		exp [][]uint8
+	}{
+		{0, [][]uint8{{1, 2, 3}, {4, 5, 6}}},
+		{1, [][]uint8{{1, 2, 3}, {4, 5, 6}}},
+		{2, [][]uint8{{3, 2, 1}, {6, 5, 4}}},
+		{3, [][]uint8{{6, 5, 4}, {3, 2, 1}}},
+		{4, [][]uint8{{4, 5, 6}, {1, 2, 3}}},
+		{5, [][]uint8{{1, 4}, {2, 5}, {3, 6}}},
+		{6, [][]uint8{{4, 1}, {5, 2}, {6, 3}}},
+		{7, [][]uint8{{6, 3}, {5, 2}, {4, 1}}},
+		{8, [][]uint8{{3, 6}, {2, 5}, {1, 4}}},
+	}
+	// the 3x2 source, offset within a larger image
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 3))
+	for y := range 2 {
+		for x := range 3 {
+			src.Pix[src.PixOffset(x+1, y+1)] = uint8(y*3 + x + 1)
+		}
+	}
+	img := src.SubImage(image.Rect(1, 1, 4, 3))
+	for i, test := range tests {
+		dst := orient(img, test.o).(*image.NRGBA)
+		if w, h := dst.Rect.Dx(), dst.Rect.Dy(); w != len(test.exp[0]) || h != len(test.exp) {
+			t.Errorf("test %d (%d) expected %dx%d, got: %dx%d", i, test.o, len(test.exp[0]), len(test.exp), w, h)
+			continue
+		}
+		for y, row := range test.exp {
+			for x, exp := range row {
+				if v := dst.Pix[dst.PixOffset(x, y)]; v != exp {
+					t.Errorf("test %d (%d) expected %d at %d,%d, got: %d", i, test.o, exp, x, y, v)
+				}
+			}
+		}
+	}
+}
+
+// testTIFF returns tiff encoded exif data having the orientation.
+func testTIFF(order binary.ByteOrder, o uint16) []byte {
+	buf := make([]byte, 8+2+12+4)
+	if order == binary.LittleEndian {
+		copy(buf, "II*\x00")
+	} else {
+		copy(buf, "MM\x00*")
+	}
+	order.PutUint32(buf[4:], 8)
+	order.PutUint16(buf[8:], 1)
+	// short orientation entry
+	order.PutUint16(buf[10:], 0x0112)
+	order.PutUint16(buf[12:], 3)
+	order.PutUint32(buf[14:], 1)
+	order.PutUint16(buf[18:], o)
+	return buf
+}
+
+// testJPEG returns the start of a jpeg having an app0 segment, and an app1
+// segment containing the exif data when not nil.
+func testJPEG(exif []byte) []byte {
+	buf := bytes.NewBuffer([]byte{0xff, 0xd8})
+	segment := func(marker byte, data []byte) {
+		buf.Write([]byte{0xff, marker, byte((len(data) + 2) >> 8), byte(len(data) + 2)})
+		buf.Write(data)
+	}
+	segment(0xe0, []byte("JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00"))
+	if exif != nil {
+		segment(0xe1, append(slices.Clone(exifHeader), exif...))
+	}
+	segment(0xda, []byte{0x00})
+	return buf.Bytes()
+}
+
+// testWebP returns a webp having an exif chunk containing the exif data
+// when not nil.
+func testWebP(exif []byte) []byte {
+	chunks := new(bytes.Buffer)
+	writeRIFFChunk(chunks, "VP8X", make([]byte, 10))
+	if exif != nil {
+		writeRIFFChunk(chunks, "EXIF", exif)
+	}
+	buf := bytes.NewBufferString("RIFF")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(4+chunks.Len()))
+	buf.WriteString("WEBP")
+	buf.Write(chunks.Bytes())
+	return buf.Bytes()
+}
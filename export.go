@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cshum/vipsgen/vips"
+)
+
+// export writes the rendered targets to the output file, or to stdout as png
+// when no output file was specified. When there are multiple targets, the
+// target's number is added to the output file name, followed by the page
+// number when pages were selected. Errors for each target are written to w,
+// and an error is returned when any target failed.
+func (args *Args) export(w io.Writer, targets []target) error {
+	if _, err := outputFormat(args.Output); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	if args.Grid {
+		img := args.grid(w, targets)
+		if img == nil {
+			return errors.New("export: no images")
+		}
+		return args.writeImage(args.Output, args.scale(img))
+	}
+	switch stdout := args.Output == "" || args.Output == "-"; {
+	case len(targets) == 0:
+		return errors.New("export: no images")
+	case len(targets) > 1 && stdout:
+		return errors.New("export: multiple targets require --output or --grid")
+	case len(args.pageRanges) != 0 && stdout:
+		return errors.New("export: pages require --output or --grid")
+	}
+	var failed int
+	for i, v := range targets {
+		if args.ctx.Err() != nil {
+			return args.ctx.Err()
+		}
+		var suffix string
+		if len(targets) > 1 {
+			suffix = "-" + strconv.Itoa(i+1)
+		}
+		switch err := args.exportTarget(suffix, v); {
+		case err != nil && len(targets) == 1:
+			return fmt.Errorf("export %q: %w", v.path, err)
+		case err != nil:
+			fmt.Fprintf(w, "error: export %q: %v\n", v.path, err)
+			failed++
+		}
+	}
+	if failed != 0 {
+		return fmt.Errorf("export: %d of %d targets failed", failed, len(targets))
+	}
+	return nil
+}
+
+// exportTarget renders the selected pages of the target v, or the target, to
+// the output file having the suffix and page number added to its name,
+// prepared the same as when displayed. Animations and videos are exported as
+// their first frame or snapshot.
+func (args *Args) exportTarget(suffix string, v target) error {
+	defer args.removeTemps()
+	for _, it := range args.decodeTarget(v) {
+		pathName, s := args.Output, suffix
+		if it.page != 0 {
+			s += "-" + strconv.Itoa(it.page)
+		}
+		if s != "" {
+			ext := filepath.Ext(pathName)
+			pathName = strings.TrimSuffix(pathName, ext) + s + ext
+		}
+		img, mime, err := it.decode()
+		if err != nil {
+			return err
+		}
+		switch x := img.(type) {
+		case *animation:
+			img = x.Image
+		case *video:
+			img = x.Image
+		}
+		if img, _, err = args.prepare(img, mime); err != nil {
+			return err
+		}
+		if err := args.writeImage(pathName, img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeImage encodes the image in the format of the file's extension, writing
+// it to the file. Writes png to stdout when the file is empty or "-".
+func (args *Args) writeImage(pathName string, img image.Image) error {
+	typ, err := outputFormat(pathName)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	var buf []byte
+	switch typ {
+	case "png":
+		buf, err = encodePNG(img)
+	case "jpeg":
+		buf, err = args.encodeJPEG(img)
+	case "webp":
+		buf, err = args.encodeWebP(img)
+	}
+	if err != nil {
+		return err
+	}
+	args.logger("encode %s: %v", pathName, time.Since(start))
+	if pathName == "" || pathName == "-" {
+		_, err := os.Stdout.Write(buf)
+		return err
+	}
+	return os.WriteFile(pathName, buf, 0o644)
+}
+
+// outputFormat returns the image format (png, jpeg, or webp) for the output
+// file's extension. The format is png when the file is empty or "-".
+func outputFormat(pathName string) (string, error) {
+	switch typ := strings.ToLower(strings.TrimPrefix(filepath.Ext(pathName), ".")); {
+	case pathName == "", pathName == "-", typ == "png":
+		return "png", nil
+	case typ == "jpg", typ == "jpeg":
+		return "jpeg", nil
+	case typ == "webp":
+		return typ, nil
+	default:
+		return "", fmt.Errorf("unsupported output format %q", typ)
+	}
+}
+
+// encodePNG encodes the image as a png.
+func encodePNG(img image.Image) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeJPEG encodes the image as a jpeg. As jpeg does not support
+// transparency, the image is first drawn on the background color, or white
+// when the background is transparent.
+func (args *Args) encodeJPEG(img image.Image) ([]byte, error) {
+	c := color.NRGBA{0xff, 0xff, 0xff, 0xff}
+	if args.bgc != nil {
+		c = *args.bgc
+		c.A = 0xff
+	}
+	b := img.Bounds()
+	dst := image.NewRGBA(b)
+	draw.Draw(dst, b, &image.Uniform{c}, image.Point{}, draw.Src)
+	draw.Draw(dst, b, img, b.Min, draw.Over)
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, dst, &jpeg.Options{Quality: 90}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeWebP encodes the image as a webp using vips.
+func (args *Args) encodeWebP(img image.Image) ([]byte, error) {
+	vipsOnce.Do(vipsInit(args.logger, args.Verbose, int(args.VipsConcurrency)))
+	buf, err := encodePNG(img)
+	if err != nil {
+		return nil, err
+	}
+	v, err := vips.NewImageFromBuffer(buf, nil)
+	if err != nil {
+		return nil, fmt.Errorf("vips load: %w", err)
+	}
+	defer v.Close()
+	if buf, err = v.WebpsaveBuffer(&vips.WebpsaveBufferOptions{Q: 90}); err != nil {
+		return nil, fmt.Errorf("vips webp: %w", err)
+	}
+	return buf, nil
+}
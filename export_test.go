@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestOutputFormat(t *testing.T) {
+	tests := []struct {
+		pathName string
+		exp      string
+		err      bool
+	}{
+		{"", "png", false},
+		{"-", "png", false},
+		{"a.png", "png", false},
+		{"a.PNG", "png", false},
+		{"a.jpg", "jpeg", false},
+		{"dir.d/a.jpeg", "jpeg", false},
+		{"a.webp", "webp", false},
+		{"a.bmp", "", true},
+		{"a", "", true},
+		{"a.png.gz", "", true},
+	}
+	for i, test := range tests {
+		typ, err := outputFormat(test.pathName)
+		switch {
+		case test.err && err == nil:
+			t.Errorf("test %d %q expected error, got: %q", i, test.pathName, typ)
+		case !test.err && err != nil:
+			t.Errorf("test %d %q expected no error, got: %v", i, test.pathName, err)
+		case typ != test.exp:
+			t.Errorf("test %d %q expected %q, got: %q", i, test.pathName, test.exp, typ)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", dir)
+	img := testImage(3, 2, func(int, int) color.NRGBA { return color.NRGBA{0xff, 0, 0, 0xff} })
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	a, b := filepath.Join(dir, "a.png"), filepath.Join(dir, "b.png")
+	for _, pathName := range []string{a, b} {
+		if err := os.WriteFile(pathName, buf.Bytes(), 0o644); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	// a three frame gif
+	g := &gif.GIF{}
+	for range 3 {
+		frame := image.NewPaletted(image.Rect(0, 0, 3, 2), color.Palette{color.White, color.Black})
+		g.Image, g.Delay = append(g.Image, frame), append(g.Delay, 1)
+	}
+	buf.Reset()
+	if err := gif.EncodeAll(buf, g); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	c := filepath.Join(dir, "c.gif")
+	if err := os.WriteFile(c, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	tests := []struct {
+		paths  []string
+		output string
+		pages  string
+		exp    []string
+		format string
+		err    bool
+	}{
+		{[]string{a}, "out.png", "", []string{"out.png"}, "png", false},
+		{[]string{a}, "out.JPG", "", []string{"out.JPG"}, "jpeg", false},
+		{[]string{a, b}, "out.png", "", []string{"out-1.png", "out-2.png"}, "png", false},
+		{[]string{a, b}, "out.jpeg", "", []string{"out-1.jpeg", "out-2.jpeg"}, "jpeg", false},
+		{[]string{c}, "out.png", "1,3", []string{"out-1.png", "out-3.png"}, "png", false},
+		{[]string{c, a}, "out.png", "3", []string{"out-1-3.png"}, "png", true},
+		{[]string{filepath.Join(dir, "missing.png"), b}, "out.png", "", []string{"out-2.png"}, "png", true},
+		{[]string{a}, "out.bmp", "", nil, "", true},
+		{[]string{a, b}, "", "", nil, "", true},
+		{[]string{a, b}, "-", "", nil, "", true},
+		{[]string{c}, "", "1", nil, "", true},
+		{nil, "out.png", "", nil, "", true},
+	}
+	for i, test := range tests {
+		out := t.TempDir()
+		var targets []target
+		for _, pathName := range test.paths {
+			targets = append(targets, target{path: pathName})
+		}
+		args := &Args{
+			ctx:        context.Background(),
+			logger:     t.Logf,
+			NoCache:    true,
+			Zoom:       1,
+			Gamma:      1,
+			Saturation: 1,
+			config:     &config{},
+		}
+		if test.output != "" && test.output != "-" {
+			args.Output = filepath.Join(out, test.output)
+		} else {
+			args.Output = test.output
+		}
+		if test.pages != "" {
+			var err error
+			if args.pageRanges, err = parsePages(test.pages); err != nil {
+				t.Fatalf("test %d expected no error, got: %v", i, err)
+			}
+		}
+		err := args.export(new(bytes.Buffer), targets)
+		switch {
+		case test.err && err == nil:
+			t.Errorf("test %d expected error", i)
+		case !test.err && err != nil:
+			t.Errorf("test %d expected no error, got: %v", i, err)
+		}
+		entries, err := os.ReadDir(out)
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+			f, err := os.Open(filepath.Join(out, entry.Name()))
+			if err != nil {
+				t.Fatalf("test %d expected no error, got: %v", i, err)
+			}
+			cfg, format, err := image.DecodeConfig(f)
+			_ = f.Close()
+			switch {
+			case err != nil:
+				t.Errorf("test %d %s expected no error, got: %v", i, entry.Name(), err)
+			case format != test.format || cfg.Width != 3 || cfg.Height != 2:
+				t.Errorf("test %d %s expected %s 3x2, got: %s %dx%d", i, entry.Name(), test.format, format, cfg.Width, cfg.Height)
+			}
+		}
+		if !slices.Equal(names, test.exp) {
+			t.Errorf("test %d expected %v, got: %v", i, test.exp, names)
+		}
+	}
+}
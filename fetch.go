@@ -0,0 +1,260 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// renderURL fetches and renders a http/s URL.
+func (args *Args) renderURL(urlstr string) (image.Image, string, error) {
+	start := time.Now()
+	res, err := args.get(urlstr, true)
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+	// use content type when provided, and extension from the final url
+	typ := contentType(res.Header.Get("Content-Type"))
+	ext := path.Ext(res.Request.URL.Path)
+	if m := mimetype.Lookup(typ); ext == "" && m != nil {
+		ext = m.Extension()
+	}
+	args.logger("content-type: %q ext: %q", typ, ext)
+	pathName, err := spool(res.Body, ext)
+	if err != nil {
+		return nil, "", fmt.Errorf("fetch %s: %w", urlstr, err)
+	}
+	args.temps = append(args.temps, pathName)
+	args.logger("fetch: %v", time.Since(start))
+	args.logger("fetch spooled: %s", pathName)
+	if typ != "" {
+		if _, _, _, err := args.decoder(typ, fileExt(pathName)); err != nil {
+			typ = ""
+		}
+	}
+	return args.renderFile(pathName, typ)
+}
+
+// get retrieves the http/s URL, returning the response when the response
+// status is OK. The user's headers and netrc credentials are only sent when
+// creds is true, and should not be used with URLs from untrusted content
+// (ie, images referenced by a markdown file).
+func (args *Args) get(urlstr string, creds bool) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(args.ctx, "GET", urlstr, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetch: %w", err)
+	}
+	req.Header.Set("User-Agent", name+"/"+version)
+	if creds {
+		for _, h := range args.Header {
+			k, v, ok := strings.Cut(h, ":")
+			if !ok {
+				return nil, fmt.Errorf("fetch: invalid header %q", h)
+			}
+			req.Header.Set(strings.TrimSpace(k), strings.TrimSpace(v))
+		}
+		args.netrcAuth(req, true)
+	}
+	cl := &http.Client{
+		Timeout: time.Duration(args.HTTPTimeout) * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if uint(len(via)) >= args.HTTPRedirects {
+				return fmt.Errorf("stopped after %d redirects", args.HTTPRedirects)
+			}
+			args.logger("fetch redirect: %s", req.URL)
+			// the default entry is not sent to other hosts
+			if creds {
+				args.netrcAuth(req, req.URL.Hostname() == via[0].URL.Hostname())
+			}
+			return nil
+		},
+	}
+	args.logger("fetch: %s", urlstr)
+	res, err := cl.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch: %w", err)
+	}
+	switch n := int64(args.HTTPMaxSize) << 20; {
+	case res.StatusCode != http.StatusOK:
+		defer res.Body.Close()
+		return nil, fmt.Errorf("fetch %s: %s", urlstr, res.Status)
+	case n != 0 && n < res.ContentLength:
+		defer res.Body.Close()
+		return nil, fmt.Errorf("fetch %s: content length %d exceeds max size %d", urlstr, res.ContentLength, n)
+	case n != 0:
+		res.Body = &maxReader{res.Body, n}
+	}
+	return res, nil
+}
+
+// netrcAuth sets the request's basic auth credentials from the user's netrc
+// file, if a matching machine entry is present. The default entry is only used
+// when def is true.
+func (args *Args) netrcAuth(req *http.Request, def bool) {
+	if req.Header.Get("Authorization") != "" {
+		return
+	}
+	if u := req.URL.User; u != nil {
+		pass, _ := u.Password()
+		req.SetBasicAuth(u.Username(), pass)
+		return
+	}
+	netrcOnce.Do(func() {
+		var err error
+		if netrcEntries, err = loadNetrc(); err != nil {
+			args.logger("netrc: %v", err)
+		}
+	})
+	host := req.URL.Hostname()
+	if entry, ok := netrcLookup(netrcEntries, host, def); ok {
+		args.logger("netrc: using login %q for %s", entry.login, host)
+		req.SetBasicAuth(entry.login, entry.password)
+	}
+}
+
+// netrcLookup returns the netrc entry for the host, falling back to the
+// default entry when def is true.
+func netrcLookup(entries []netrcEntry, host string, def bool) (netrcEntry, bool) {
+	for _, entry := range entries {
+		if entry.machine == host || entry.machine == "" && def {
+			return entry, true
+		}
+	}
+	return netrcEntry{}, false
+}
+
+// netrcEntry is a netrc machine entry. The default entry has an empty
+// machine.
+type netrcEntry struct {
+	machine  string
+	login    string
+	password string
+}
+
+// loadNetrc loads the entries from the user's netrc file, as specified by
+// $NETRC or the default location. The default entry, if any, is last.
+func loadNetrc() ([]netrcEntry, error) {
+	pathName := os.Getenv("NETRC")
+	if pathName == "" {
+		dir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		pathName = filepath.Join(dir, ".netrc")
+		if runtime.GOOS == "windows" {
+			pathName = filepath.Join(dir, "_netrc")
+		}
+	}
+	buf, err := os.ReadFile(pathName)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	return parseNetrc(string(buf)), nil
+}
+
+// parseNetrc parses the netrc file contents.
+func parseNetrc(s string) []netrcEntry {
+	var entries []netrcEntry
+	var def *netrcEntry
+	var entry *netrcEntry
+	var macdef bool
+	for line := range strings.SplitSeq(s, "\n") {
+		fields := strings.Fields(line)
+		// macro definitions end at a blank line
+		if macdef {
+			macdef = len(fields) != 0
+			continue
+		}
+		for i := 0; i < len(fields); i++ {
+			next := func() string {
+				if i++; i < len(fields) {
+					return fields[i]
+				}
+				return ""
+			}
+			switch fields[i] {
+			case "machine":
+				entries = append(entries, netrcEntry{machine: next()})
+				entry = &entries[len(entries)-1]
+			case "default":
+				def = &netrcEntry{}
+				entry = def
+			case "login":
+				if s := next(); entry != nil {
+					entry.login = s
+				}
+			case "password":
+				if s := next(); entry != nil {
+					entry.password = s
+				}
+			case "account":
+				_ = next()
+			case "macdef":
+				macdef, i = true, len(fields)
+			}
+		}
+	}
+	if def != nil {
+		entries = append(entries, *def)
+	}
+	return entries
+}
+
+// contentType returns the media type of the content type header, or empty
+// when it is generic.
+func contentType(s string) string {
+	typ, _, err := mime.ParseMediaType(s)
+	if err != nil {
+		return ""
+	}
+	switch typ = strings.ToLower(typ); typ {
+	case
+		"application/octet-stream",
+		"application/force-download",
+		"application/x-download",
+		"binary/octet-stream",
+		"text/plain":
+		return ""
+	}
+	if m := mimetype.Lookup(typ); m != nil {
+		typ, _, _ = strings.Cut(m.String(), ";")
+	}
+	return strings.TrimSuffix(typ, "+xml")
+}
+
+// maxReader wraps a reader, returning an error after more than n bytes have
+// been read.
+type maxReader struct {
+	io.ReadCloser
+	n int64
+}
+
+// Read satisfies the [io.Reader] interface.
+func (r *maxReader) Read(buf []byte) (int, error) {
+	n, err := r.ReadCloser.Read(buf)
+	if r.n -= int64(n); r.n < 0 {
+		return n, errors.New("exceeded max size")
+	}
+	return n, err
+}
+
+var (
+	netrcOnce    sync.Once
+	netrcEntries []netrcEntry
+)
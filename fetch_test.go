@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseNetrc(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp []netrcEntry
+	}{
+		{"", nil},
+		{"machine a.com login user password pass", []netrcEntry{{"a.com", "user", "pass"}}},
+		{
+			"machine a.com\n  login user\n  password pass\nmachine b.com login other",
+			[]netrcEntry{{"a.com", "user", "pass"}, {"b.com", "other", ""}},
+		},
+		{
+			"default login anon password x\nmachine a.com login user password pass",
+			[]netrcEntry{{"a.com", "user", "pass"}, {"", "anon", "x"}},
+		},
+		{
+			"machine a.com login user account acct password pass",
+			[]netrcEntry{{"a.com", "user", "pass"}},
+		},
+		{
+			"macdef init\nmachine x.com login macro\n\nmachine a.com login user",
+			[]netrcEntry{{"a.com", "user", ""}},
+		},
+		{"login orphan password x\nmachine a.com", []netrcEntry{{"a.com", "", ""}}},
+		{"machine a.com login", []netrcEntry{{"a.com", "", ""}}},
+	}
+	for i, test := range tests {
+		if entries := parseNetrc(test.s); !slices.Equal(entries, test.exp) {
+			t.Errorf("test %d expected %v, got: %v", i, test.exp, entries)
+		}
+	}
+}
+
+func TestContentType(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp string
+	}{
+		{"", ""},
+		{"image/png", "image/png"},
+		{"IMAGE/PNG; charset=binary", "image/png"},
+		{"application/octet-stream", ""},
+		{"text/plain; charset=utf-8", ""},
+		{"invalid;;", ""},
+	}
+	for i, test := range tests {
+		if s := contentType(test.s); s != test.exp {
+			t.Errorf("test %d %q expected %q, got: %q", i, test.s, test.exp, s)
+		}
+	}
+}
+
+func TestNetrcLookup(t *testing.T) {
+	entries := []netrcEntry{{"a.com", "user", "pass"}, {"b.com", "other", ""}, {"", "anon", "x"}}
+	tests := []struct {
+		entries []netrcEntry
+		host    string
+		def     bool
+		exp     string
+		ok      bool
+	}{
+		{entries, "a.com", true, "user", true},
+		{entries, "a.com", false, "user", true},
+		{entries, "b.com", false, "other", true},
+		{entries, "c.com", true, "anon", true},
+		{entries, "c.com", false, "", false},
+		{entries[:2], "c.com", true, "", false},
+		{nil, "a.com", true, "", false},
+	}
+	for i, test := range tests {
+		entry, ok := netrcLookup(test.entries, test.host, test.def)
+		if entry.login != test.exp || ok != test.ok {
+			t.Errorf("test %d expected %q %t, got: %q %t", i, test.exp, test.ok, entry.login, ok)
+		}
+	}
+}
+
+func TestGetRedirectNetrc(t *testing.T) {
+	netrcOnce.Do(func() {})
+	prev := netrcEntries
+	t.Cleanup(func() { netrcEntries = prev })
+	netrcEntries = []netrcEntry{{"", "anon", "x"}}
+	var auth string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		auth = req.Header.Get("Authorization")
+	}))
+	defer other.Close()
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/same":
+			http.Redirect(w, req, "/final", http.StatusFound)
+		case "/other":
+			http.Redirect(w, req, otherURL+"/final", http.StatusFound)
+		case "/final":
+			auth = req.Header.Get("Authorization")
+		}
+	}))
+	defer srv.Close()
+	tests := []struct {
+		path  string
+		creds bool
+		exp   bool
+	}{
+		{"/final", true, true},
+		{"/final", false, false},
+		{"/same", true, true},
+		{"/other", true, false},
+	}
+	for i, test := range tests {
+		auth = ""
+		args := &Args{ctx: context.Background(), logger: t.Logf, HTTPRedirects: 10}
+		res, err := args.get(srv.URL+test.path, test.creds)
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		_ = res.Body.Close()
+		if (auth != "") != test.exp {
+			t.Errorf("test %d expected auth %t, got: %q", i, test.exp, auth)
+		}
+	}
+}
@@ -3,6 +3,7 @@ module github.com/kenshaw/iv
 go 1.26
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/cshum/vipsgen v1.3.10
 	github.com/dhowden/tag v0.0.0-20240417053706-3d75831295e8
 	github.com/gabriel-vasile/mimetype v1.4.15
@@ -23,6 +24,7 @@ require (
 	github.com/xo/resvg v0.7.0
 	github.com/yuin/goldmark v1.8.5
 	golang.org/x/image v0.44.0
+	golang.org/x/sys v0.47.0
 	golang.org/x/term v0.45.0
 )
 
@@ -72,7 +74,6 @@ require (
 	go4.org v0.0.0-20260112195520-a5071408f32f // indirect
 	golang.org/x/net v0.57.0 // indirect
 	golang.org/x/sync v0.22.0 // indirect
-	golang.org/x/sys v0.47.0 // indirect
 	golang.org/x/text v0.40.0 // indirect
 	modernc.org/knuth v0.5.5 // indirect
 	modernc.org/token v1.1.0 // indirect
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// renderGrid renders the targets as a single grid of thumbnails to w. When
+// there is only one target, the selected pages, or all pages, of the target
+// are rendered instead.
+func (args *Args) renderGrid(w io.Writer, targets []target) error {
+	img := args.grid(w, targets)
+	if img == nil {
+		return nil
+	}
+	img = args.scale(img)
+	start := time.Now()
+	if err := args.encode(w, img); err != nil {
+		return err
+	}
+	args.logger("encode out: %v", time.Since(start))
+	return nil
+}
+
+// grid composes the targets into a single grid of thumbnails, writing decode
+// errors to w. Returns nil when no target could be decoded.
+func (args *Args) grid(w io.Writer, targets []target) image.Image {
+	defer args.removeTemps()
+	start := time.Now()
+	var cells []gridCell
+	for _, v := range targets {
+		img, mime, err := args.decode(v)
+		if err != nil {
+			fmt.Fprintf(w, "error: render %q: %v\n\n", v.path, err)
+			continue
+		}
+		file, n := args.file, max(args.pages, 1)
+		if len(targets) != 1 || file == "" || n < 2 && len(args.pageRanges) == 0 {
+			if img, err = args.transform(img); err != nil {
+				fmt.Fprintf(w, "error: render %q: %v\n\n", v.path, err)
+				continue
+			}
+			cells = append(cells, args.gridCell(mime, img, filepath.Base(v.path)))
+			continue
+		}
+		// add selected pages, or all pages
+		ranges := args.pageRanges
+		if len(ranges) == 0 {
+			ranges = []pageRange{{1, 0}}
+		}
+		pages, err := resolvePages(ranges, n)
+		if err != nil {
+			fmt.Fprintf(w, "error: render %q: %v\n\n", v.path, err)
+			continue
+		}
+		for _, p := range pages {
+			img, err := args.decodePage(img, mime, file, p)
+			if err == nil {
+				img, err = args.transform(img)
+			}
+			if err != nil {
+				fmt.Fprintf(w, "error: render %q page %d: %v\n\n", v.path, p, err)
+				continue
+			}
+			cells = append(cells, args.gridCell(mime, img, strconv.Itoa(p)))
+		}
+	}
+	// restore the options of the last target's mime type
+	args.resetConfig()
+	if len(cells) == 0 {
+		return nil
+	}
+	args.logger("grid decode: %v", time.Since(start))
+	start = time.Now()
+	cols := int(args.GridCols)
+	if cols == 0 {
+		cols = min(len(cells), 8)
+	}
+	img := args.composeGrid(cells, int(args.GridSize), int(args.GridSize), cols)
+	args.logger("grid compose: %v", time.Since(start))
+	return img
+}
+
+// gridCell creates a grid cell for the image, scaled to fit the grid size, so
+// that the full size image is not retained until the grid is composed.
+func (args *Args) gridCell(mime string, img image.Image, caption string) gridCell {
+	size := int(args.GridSize)
+	b := img.Bounds()
+	if w, h := fitSize(b.Dx(), b.Dy(), size, size); w != b.Dx() || h != b.Dy() {
+		dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+		img = dst
+	}
+	return gridCell{
+		img:     args.addBackground(mime, img),
+		caption: caption,
+	}
+}
+
+// composeGrid composes the cells into a single grid image, scaling each cell
+// to fit within the width and height.
+func (args *Args) composeGrid(cells []gridCell, width, height, cols int) image.Image {
+	const pad, captionHeight = 8, 16
+	rows := (len(cells) + cols - 1) / cols
+	cw, ch := width+pad, height+pad+captionHeight
+	dst := image.NewNRGBA(image.Rect(0, 0, cols*cw+pad, rows*ch+pad))
+	if args.bgc != nil {
+		draw.Draw(dst, dst.Bounds(), &image.Uniform{*args.bgc}, image.Point{}, draw.Src)
+	}
+	d := &font.Drawer{
+		Dst:  dst,
+		Src:  &image.Uniform{args.Fg},
+		Face: basicfont.Face7x13,
+	}
+	for i, cell := range cells {
+		x, y := pad+(i%cols)*cw, pad+(i/cols)*ch
+		// scale to fit cell, centered
+		b := cell.img.Bounds()
+		sw, sh := fitSize(b.Dx(), b.Dy(), width, height)
+		r := image.Rect(0, 0, sw, sh).Add(image.Pt(x+(width-sw)/2, y+(height-sh)/2))
+		draw.CatmullRom.Scale(dst, r, cell.img, b, draw.Over, nil)
+		// caption
+		s := truncate(cell.caption, width/basicfont.Face7x13.Advance)
+		d.Dot = fixed.P(x+(width-len([]rune(s))*basicfont.Face7x13.Advance)/2, y+height+captionHeight-2)
+		d.DrawString(s)
+	}
+	return dst
+}
+
+// gridCell is a grid cell.
+type gridCell struct {
+	img     image.Image
+	caption string
+}
+
+// fitSize returns the largest size having the same aspect ratio as w, h that
+// fits within the maximum width and height. Does not scale up.
+func fitSize(w, h, maxWidth, maxHeight int) (int, int) {
+	if w <= maxWidth && h <= maxHeight || w == 0 || h == 0 {
+		return w, h
+	}
+	scale := min(float64(maxWidth)/float64(w), float64(maxHeight)/float64(h))
+	return max(1, int(float64(w)*scale)), max(1, int(float64(h)*scale))
+}
+
+// truncate truncates s to n runes, adding an ellipsis when truncated.
+func truncate(s string, n int) string {
+	switch r := []rune(s); {
+	case len(r) <= n:
+		return s
+	case n <= 3:
+		return string(r[:max(n, 0)])
+	default:
+		return string(r[:n-3]) + "..."
+	}
+}
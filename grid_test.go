@@ -0,0 +1,135 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/kenshaw/colors"
+)
+
+func TestFitSize(t *testing.T) {
+	tests := []struct {
+		w, h, mw, mh int
+		ew, eh       int
+	}{
+		{10, 10, 20, 20, 10, 10},
+		{20, 20, 20, 20, 20, 20},
+		{40, 20, 20, 20, 20, 10},
+		{20, 40, 20, 20, 10, 20},
+		{100, 10, 20, 20, 20, 2},
+		{1000, 1, 20, 20, 20, 1},
+		{0, 10, 5, 5, 0, 10},
+	}
+	for i, test := range tests {
+		if w, h := fitSize(test.w, test.h, test.mw, test.mh); w != test.ew || h != test.eh {
+			t.Errorf("test %d expected %dx%d, got: %dx%d", i, test.ew, test.eh, w, h)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s   string
+		n   int
+		exp string
+	}{
+		{"abc", 3, "abc"},
+		{"abcdef", 5, "ab..."},
+		{"abcdef", 3, "abc"},
+		{"abcdef", 0, ""},
+		{"abcdef", -1, ""},
+		{"äöüäöü", 4, "ä..."},
+	}
+	for i, test := range tests {
+		if s := truncate(test.s, test.n); s != test.exp {
+			t.Errorf("test %d expected %q, got: %q", i, test.exp, s)
+		}
+	}
+}
+
+func TestGridCell(t *testing.T) {
+	red := color.NRGBA{0xff, 0, 0, 0xff}
+	tests := []struct {
+		w, h int
+		bg   bool
+		exp  image.Rectangle
+	}{
+		{8, 4, false, image.Rect(0, 0, 8, 4)},
+		{64, 32, false, image.Rect(0, 0, 16, 8)},
+		{32, 64, true, image.Rect(0, 0, 8, 16)},
+	}
+	for i, test := range tests {
+		args := &Args{GridSize: 16, logger: t.Logf}
+		if test.bg {
+			args.bgc = &color.NRGBA{0, 0, 0xff, 0xff}
+		}
+		img := testImage(test.w, test.h, func(x, y int) color.NRGBA {
+			if x == 0 && y == 0 {
+				return color.NRGBA{}
+			}
+			return red
+		})
+		cell := args.gridCell("image/png", img, "a.png")
+		if b := cell.img.Bounds(); b != test.exp || cell.caption != "a.png" {
+			t.Errorf("test %d expected %v %q, got: %v %q", i, test.exp, "a.png", b, cell.caption)
+		}
+		// the transparent corner is only filled when there is a background
+		if c := testNRGBA(cell.img, 0, 0); (c.A == 0xff) != test.bg {
+			t.Errorf("test %d expected background %t, got: %v", i, test.bg, c)
+		}
+	}
+}
+
+func TestComposeGrid(t *testing.T) {
+	fg, err := colors.Parse("white")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	bg := color.NRGBA{0, 0, 0, 0xff}
+	red, green, blue := color.NRGBA{0xff, 0, 0, 0xff}, color.NRGBA{0, 0xff, 0, 0xff}, color.NRGBA{0, 0, 0xff, 0xff}
+	cell := func(c color.NRGBA, w, h int) gridCell {
+		return gridCell{
+			img:     testImage(w, h, func(int, int) color.NRGBA { return c }),
+			caption: "caption",
+		}
+	}
+	cells := []gridCell{cell(red, 4, 2), cell(green, 16, 16), cell(blue, 2, 4)}
+	tests := []struct {
+		cols int
+		exp  image.Rectangle
+		pts  []image.Point
+	}{
+		{1, image.Rect(0, 0, 32, 128), []image.Point{{16, 16}, {16, 56}, {16, 96}}},
+		{2, image.Rect(0, 0, 56, 88), []image.Point{{16, 16}, {40, 16}, {16, 56}}},
+		{3, image.Rect(0, 0, 80, 48), []image.Point{{16, 16}, {40, 16}, {64, 16}}},
+		{4, image.Rect(0, 0, 104, 48), []image.Point{{16, 16}, {40, 16}, {64, 16}}},
+	}
+	for i, test := range tests {
+		args := &Args{Fg: &fg, bgc: &bg}
+		img := args.composeGrid(cells, 16, 16, test.cols)
+		if b := img.Bounds(); b != test.exp {
+			t.Fatalf("test %d expected %v, got: %v", i, test.exp, b)
+		}
+		// cells are centered at the points
+		for j, c := range []color.NRGBA{red, green, blue} {
+			if got := testNRGBA(img, test.pts[j].X, test.pts[j].Y); got != c {
+				t.Errorf("test %d cell %d expected %v, got: %v", i, j, c, got)
+			}
+		}
+		// red cell is not scaled up
+		if got := testNRGBA(img, 13, 16); got != bg {
+			t.Errorf("test %d expected %v, got: %v", i, bg, got)
+		}
+		// caption is drawn beneath the first cell
+		var caption bool
+		for y := 24; y < 40 && !caption; y++ {
+			for x := 8; x < 24 && !caption; x++ {
+				caption = testNRGBA(img, x, y) != bg
+			}
+		}
+		if !caption {
+			t.Errorf("test %d expected caption", i)
+		}
+	}
+}
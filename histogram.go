@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+	"slices"
+)
+
+// histogram is the per-channel histogram and statistics of an image. The
+// color and luminance channels only count pixels that are not fully
+// transparent.
+type histogram struct {
+	bins      [5][256]int
+	n         [5]int
+	sum, sum2 [5]float64
+}
+
+// newHistogram creates the histogram of the image.
+func newHistogram(img image.Image) *histogram {
+	src := toNRGBA(img)
+	h := new(histogram)
+	for i := 0; i < len(src.Pix); i += 4 {
+		c := color.NRGBA{src.Pix[i], src.Pix[i+1], src.Pix[i+2], src.Pix[i+3]}
+		h.add(3, c.A)
+		if c.A == 0 {
+			continue
+		}
+		h.add(0, c.R)
+		h.add(1, c.G)
+		h.add(2, c.B)
+		h.add(4, uint8(luminance(c)))
+	}
+	return h
+}
+
+// add adds the value to the channel.
+func (h *histogram) add(channel int, v uint8) {
+	h.bins[channel][v]++
+	h.n[channel]++
+	h.sum[channel] += float64(v)
+	h.sum2[channel] += float64(v) * float64(v)
+}
+
+// write writes the min, max, mean, and standard deviation of each channel to
+// w.
+func (h *histogram) write(w io.Writer) {
+	fmt.Fprintln(w, "histogram:")
+	for i, ch := range histogramChannels {
+		if h.n[i] == 0 {
+			fmt.Fprintf(w, "  %-12s no pixels\n", ch.name+":")
+			continue
+		}
+		bins := h.bins[i][:]
+		lo := slices.IndexFunc(bins, func(n int) bool { return n != 0 })
+		hi := 255
+		for bins[hi] == 0 {
+			hi--
+		}
+		n := float64(h.n[i])
+		mean := h.sum[i] / n
+		stddev := math.Sqrt(max(h.sum2[i]/n-mean*mean, 0))
+		fmt.Fprintf(w, "  %-12s min %3d  max %3d  mean %6.2f  stddev %6.2f\n", ch.name+":", lo, hi, mean, stddev)
+	}
+}
+
+// compose composes the image with the histogram's charts, beneath images
+// that are wider than they are tall, and otherwise beside.
+func (h *histogram) compose(img image.Image) image.Image {
+	b := img.Bounds()
+	w, ht := b.Dx(), b.Dy()
+	n := len(histogramChannels)
+	pad := max(2, max(w, ht)/100)
+	// charts are twice as wide as they are tall
+	var cw, ch int
+	var r image.Rectangle
+	if w >= ht {
+		cw = max((w-pad*(n-1))/n, 16)
+		ch = cw / 2
+		r = image.Rect(0, 0, max(w, n*cw+pad*(n-1)), ht+pad+ch)
+	} else {
+		ch = max((ht-pad*(n-1))/n, 8)
+		cw = ch * 2
+		r = image.Rect(0, 0, w+pad+cw, max(ht, n*ch+pad*(n-1)))
+	}
+	dst := image.NewNRGBA(r)
+	draw.Draw(dst, image.Rect(0, 0, w, ht), img, b.Min, draw.Src)
+	for i := range n {
+		var p image.Point
+		if w >= ht {
+			p = image.Pt(i*(cw+pad), ht+pad)
+		} else {
+			p = image.Pt(w+pad, i*(ch+pad))
+		}
+		h.chart(dst, image.Rect(0, 0, cw, ch).Add(p), i)
+	}
+	return dst
+}
+
+// chart draws the channel's histogram to the rectangle of dst, scaled to the
+// channel's largest bin.
+func (h *histogram) chart(dst *image.NRGBA, r image.Rectangle, channel int) {
+	draw.Draw(dst, r, &image.Uniform{histogramBg}, image.Point{}, draw.Src)
+	bins := h.bins[channel][:]
+	peak := slices.Max(bins)
+	if peak == 0 {
+		return
+	}
+	fg := &image.Uniform{histogramChannels[channel].color}
+	for x := range r.Dx() {
+		// the largest of the bins drawn in the column
+		lo := x * 256 / r.Dx()
+		hi := max((x+1)*256/r.Dx(), lo+1)
+		v := slices.Max(bins[lo:hi])
+		y := int(math.Round(float64(v) / float64(peak) * float64(r.Dy())))
+		draw.Draw(dst, image.Rect(r.Min.X+x, r.Max.Y-y, r.Min.X+x+1, r.Max.Y), fg, image.Point{}, draw.Src)
+	}
+}
+
+// histogramBg is the background color of the histogram charts.
+var histogramBg = color.NRGBA{0x20, 0x20, 0x20, 0xff}
+
+// histogramChannels are the histogram channel names and chart colors, in
+// order.
+var histogramChannels = []struct {
+	name  string
+	color color.NRGBA
+}{
+	{"red", color.NRGBA{0xe0, 0x40, 0x40, 0xff}},
+	{"green", color.NRGBA{0x40, 0xc0, 0x40, 0xff}},
+	{"blue", color.NRGBA{0x50, 0x70, 0xf0, 0xff}},
+	{"alpha", color.NRGBA{0xa0, 0xa0, 0xa0, 0xff}},
+	{"luminance", color.NRGBA{0xf0, 0xf0, 0xf0, 0xff}},
+}
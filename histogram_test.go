@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestNewHistogram(t *testing.T) {
+	h := newHistogram(testHistogramImage())
+	tests := []struct {
+		channel int
+		n       int
+		bins    map[int]int
+	}{
+		{0, 3, map[int]int{0: 1, 100: 1, 255: 1}},
+		{1, 3, map[int]int{0: 1, 100: 1, 255: 1}},
+		{2, 3, map[int]int{0: 2, 100: 1}},
+		{3, 4, map[int]int{0: 1, 128: 1, 255: 2}},
+		{4, 3, map[int]int{76: 1, 100: 1, 149: 1}},
+	}
+	for i, test := range tests {
+		if n := h.n[test.channel]; n != test.n {
+			t.Errorf("test %d expected %d pixels, got: %d", i, test.n, n)
+		}
+		for v, n := range h.bins[test.channel] {
+			if exp := test.bins[v]; n != exp {
+				t.Errorf("test %d expected bin %d to be %d, got: %d", i, v, exp, n)
+			}
+		}
+	}
+}
+
+func TestHistogramWrite(t *testing.T) {
+	tests := []struct {
+		img image.Image
+		exp string
+	}{
+		{
+			testHistogramImage(),
+			"histogram:\n" +
+				"  red:         min   0  max 255  mean 118.33  stddev 104.91\n" +
+				"  green:       min   0  max 255  mean 118.33  stddev 104.91\n" +
+				"  blue:        min   0  max 100  mean  33.33  stddev  47.14\n" +
+				"  alpha:       min   0  max 255  mean 159.50  stddev 105.68\n" +
+				"  luminance:   min  76  max 149  mean 108.33  stddev  30.38\n",
+		},
+		{
+			testImage(2, 2, func(int, int) color.NRGBA { return color.NRGBA{0xff, 0, 0, 0} }),
+			"histogram:\n" +
+				"  red:         no pixels\n" +
+				"  green:       no pixels\n" +
+				"  blue:        no pixels\n" +
+				"  alpha:       min   0  max   0  mean   0.00  stddev   0.00\n" +
+				"  luminance:   no pixels\n",
+		},
+	}
+	for i, test := range tests {
+		buf := new(bytes.Buffer)
+		newHistogram(test.img).write(buf)
+		if s := buf.String(); s != test.exp {
+			t.Errorf("test %d expected:\n%s\ngot:\n%s", i, test.exp, s)
+		}
+	}
+}
+
+func TestHistogramCompose(t *testing.T) {
+	red := color.NRGBA{0xff, 0, 0, 0xff}
+	tests := []struct {
+		w, h int
+		exp  image.Rectangle
+		// the bottom left of the red and green charts
+		r, g image.Point
+		ch   int
+	}{
+		// beneath: 18x9 charts with 2px padding
+		{100, 50, image.Rect(0, 0, 100, 61), image.Pt(0, 60), image.Pt(20, 60), 9},
+		// beside: 36x18 charts with 2px padding
+		{50, 100, image.Rect(0, 0, 88, 100), image.Pt(52, 17), image.Pt(52, 37), 18},
+		// minimum chart size
+		{4, 4, image.Rect(0, 0, 88, 14), image.Pt(0, 13), image.Pt(18, 13), 8},
+	}
+	for i, test := range tests {
+		img := testImage(test.w, test.h, func(int, int) color.NRGBA { return red })
+		dst := newHistogram(img).compose(img)
+		if b := dst.Bounds(); b != test.exp {
+			t.Fatalf("test %d expected %v, got: %v", i, test.exp, b)
+		}
+		if c := testNRGBA(dst, test.w-1, test.h-1); c != red {
+			t.Errorf("test %d expected %v, got: %v", i, red, c)
+		}
+		// red is only in the last bin, and green is only in the first bin,
+		// both drawn at full height
+		rc, gc := histogramChannels[0].color, histogramChannels[1].color
+		for _, pt := range []struct {
+			p   image.Point
+			exp color.NRGBA
+		}{
+			{test.r, histogramBg},
+			{test.r.Add(image.Pt(2*test.ch-1, 0)), rc},
+			{test.r.Add(image.Pt(2*test.ch-1, 1-test.ch)), rc},
+			{test.g, gc},
+			{test.g.Add(image.Pt(0, 1-test.ch)), gc},
+			{test.g.Add(image.Pt(2*test.ch-1, 0)), histogramBg},
+		} {
+			if c := testNRGBA(dst, pt.p.X, pt.p.Y); c != pt.exp {
+				t.Errorf("test %d expected %v at %v, got: %v", i, pt.exp, pt.p, c)
+			}
+		}
+	}
+}
+
+// testHistogramImage returns a red, green, transparent, and half transparent
+// gray image.
+func testHistogramImage() image.Image {
+	colors := []color.NRGBA{
+		{0xff, 0, 0, 0xff},
+		{0, 0xff, 0, 0xff},
+		{0xff, 0xff, 0xff, 0},
+		{100, 100, 100, 128},
+	}
+	return testImage(2, 2, func(x, y int) color.NRGBA { return colors[y*2+x] })
+}
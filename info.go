@@ -0,0 +1,592 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/cshum/vipsgen/vips"
+	"github.com/dhowden/tag"
+)
+
+// showInfo writes information about the targets to w, as text or json. When
+// displaying text, the image is displayed after the information when
+// requested.
+func (args *Args) showInfo(w io.Writer, targets []target) error {
+	var infos []*imageInfo
+	for _, v := range targets {
+		if args.ctx.Err() != nil {
+			break
+		}
+		info, img, mime := args.info(v)
+		if args.JSON {
+			infos = append(infos, info)
+			continue
+		}
+		info.write(w)
+		if args.InfoImage && img != nil {
+			if err := args.show(w, img, mime); err != nil {
+				fmt.Fprintf(w, "error: render %q: %v\n", v.path, err)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+	if !args.JSON {
+		return nil
+	}
+	buf, err := json.MarshalIndent(infos, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(buf))
+	return err
+}
+
+// info decodes the target v, returning information about it, along with the
+// decoded image and its mime type.
+func (args *Args) info(v target) (*imageInfo, image.Image, string) {
+	defer args.removeTemps()
+	img, mime, err := args.decode(v)
+	info := &imageInfo{
+		Path:    v.path,
+		Mime:    mime,
+		Decoder: args.decoderName,
+		Pages:   args.pages,
+	}
+	if err != nil {
+		info.Error = err.Error()
+	}
+	if img != nil {
+		src := img
+		switch x := img.(type) {
+		case *animation:
+			src, info.Frames = x.Image, len(x.frames)
+		case *video:
+			src = x.Image
+		}
+		b := src.Bounds()
+		info.Width, info.Height = b.Dx(), b.Dy()
+		// the decoded image's color model is only that of the source when
+		// not decoded from a file
+		if args.file == "" {
+			info.ColorModel, info.BitDepth = colorModel(src.ColorModel())
+		}
+	}
+	if args.file == "" {
+		return info, img, mime
+	}
+	start := time.Now()
+	switch {
+	case mime == "image/svg", isPdf(mime):
+	case isBuiltin(mime), isVips(mime):
+		if isBuiltin(mime) {
+			args.configInfo(info, args.file)
+		}
+		args.vipsInfo(info, args.file)
+		if info.EXIF == nil && isBuiltin(mime) {
+			if buf, err := os.ReadFile(args.file); err == nil {
+				info.EXIF = parseEXIF(exifData(buf))
+			}
+		}
+	case strings.HasPrefix(mime, "audio/"):
+		args.tagInfo(info, args.file)
+	}
+	if strings.HasPrefix(mime, "audio/") || strings.HasPrefix(mime, "video/") {
+		args.ffprobeInfo(info, args.file)
+	}
+	args.logger("info: %v", time.Since(start))
+	return info, img, mime
+}
+
+// configInfo adds the color model and bit depth of the image file to info,
+// as read from the file's header.
+func (args *Args) configInfo(info *imageInfo, pathName string) {
+	f, err := os.Open(pathName)
+	if err != nil {
+		args.logger("info: %v", err)
+		return
+	}
+	defer f.Close()
+	c, _, err := image.DecodeConfig(f)
+	if err != nil {
+		args.logger("info: decode config: %v", err)
+		return
+	}
+	info.ColorModel, info.BitDepth = colorModel(c.ColorModel)
+}
+
+// vipsInfo adds the color model and bit depth, the exif, xmp, and iptc
+// metadata, and the icc profile name of the image file to info, using vips.
+// The color model and bit depth are only added when not already known.
+func (args *Args) vipsInfo(info *imageInfo, pathName string) {
+	vipsOnce.Do(vipsInit(args.logger, args.Verbose, int(args.VipsConcurrency)))
+	v, err := vips.NewImageFromFile(pathName, nil)
+	if err != nil {
+		args.logger("info: vips load: %v", err)
+		return
+	}
+	defer v.Close()
+	if info.ColorModel == "" {
+		info.ColorModel, info.BitDepth = vipsColorModel(v)
+	}
+	blob := func(name string) []byte {
+		if !v.HasField(name) {
+			return nil
+		}
+		buf, err := v.GetBlob(name)
+		if err != nil {
+			args.logger("info: vips %s: %v", name, err)
+		}
+		return buf
+	}
+	info.EXIF = parseEXIF(blob("exif-data"))
+	info.XMP = parseXMP(blob("xmp-data"))
+	info.IPTC = parseIPTC(blob("iptc-data"))
+	info.ICCProfile = iccDescription(blob("icc-profile-data"))
+}
+
+// tagInfo adds the audio file's metadata tags to info.
+func (args *Args) tagInfo(info *imageInfo, pathName string) {
+	f, err := os.Open(pathName)
+	if err != nil {
+		args.logger("info: %v", err)
+		return
+	}
+	defer f.Close()
+	md, err := tag.ReadFrom(f)
+	if err != nil {
+		args.logger("info: tag: %v", err)
+		return
+	}
+	track, tracks := md.Track()
+	disc, discs := md.Disc()
+	info.Tags = make(map[string]string)
+	for k, s := range map[string]string{
+		"format":       string(md.Format()),
+		"file_type":    string(md.FileType()),
+		"title":        md.Title(),
+		"album":        md.Album(),
+		"artist":       md.Artist(),
+		"album_artist": md.AlbumArtist(),
+		"composer":     md.Composer(),
+		"genre":        md.Genre(),
+		"year":         formatCount(md.Year(), 0),
+		"track":        formatCount(track, tracks),
+		"disc":         formatCount(disc, discs),
+	} {
+		if s != "" {
+			info.Tags[k] = s
+		}
+	}
+	if pic := md.Picture(); pic != nil {
+		info.Tags["picture"] = pic.MIMEType
+	}
+}
+
+// ffprobeInfo adds the format and stream information of the audio or video
+// file to info, using the ffprobe command.
+func (args *Args) ffprobeInfo(info *imageInfo, pathName string) {
+	_ = ffmpegInit()
+	if ffprobePath == "" {
+		return
+	}
+	params := []string{
+		`-v`, `error`,
+		`-show_format`,
+		`-show_streams`,
+		`-of`, `json`,
+		pathName,
+	}
+	args.logger("ffprobe: executing %s %s", ffprobePath, strings.Join(params, " "))
+	buf, err := exec.CommandContext(args.ctx, ffprobePath, params...).Output()
+	if err != nil {
+		args.logger("info: ffprobe: %v", err)
+		return
+	}
+	if !json.Valid(buf) {
+		args.logger("info: ffprobe: invalid json")
+		return
+	}
+	info.Probe = json.RawMessage(buf)
+}
+
+// imageInfo is information about a target.
+type imageInfo struct {
+	Path       string            `json:"path"`
+	Mime       string            `json:"mime,omitempty"`
+	Decoder    string            `json:"decoder,omitempty"`
+	Width      int               `json:"width,omitempty"`
+	Height     int               `json:"height,omitempty"`
+	ColorModel string            `json:"color_model,omitempty"`
+	BitDepth   int               `json:"bit_depth,omitempty"`
+	Pages      int               `json:"pages,omitempty"`
+	Frames     int               `json:"frames,omitempty"`
+	ICCProfile string            `json:"icc_profile,omitempty"`
+	EXIF       map[string]string `json:"exif,omitempty"`
+	XMP        map[string]string `json:"xmp,omitempty"`
+	IPTC       map[string]string `json:"iptc,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Probe      json.RawMessage   `json:"ffprobe,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// write writes the information as text to w.
+func (info *imageInfo) write(w io.Writer) {
+	fmt.Fprintln(w, info.Path+":")
+	field := func(name string, v any) {
+		switch x := v.(type) {
+		case string:
+			if x == "" {
+				return
+			}
+		case int:
+			if x == 0 {
+				return
+			}
+		}
+		fmt.Fprintf(w, "  %-12s %v\n", name+":", v)
+	}
+	field("mime", info.Mime)
+	field("decoder", info.Decoder)
+	if info.Width != 0 || info.Height != 0 {
+		field("dimensions", fmt.Sprintf("%dx%d", info.Width, info.Height))
+	}
+	field("color model", info.ColorModel)
+	field("bit depth", info.BitDepth)
+	field("pages", info.Pages)
+	field("frames", info.Frames)
+	field("icc profile", info.ICCProfile)
+	for _, m := range []struct {
+		name   string
+		fields map[string]string
+	}{
+		{"exif", info.EXIF},
+		{"xmp", info.XMP},
+		{"iptc", info.IPTC},
+		{"tags", info.Tags},
+	} {
+		if len(m.fields) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "  %s:\n", m.name)
+		for _, k := range slices.Sorted(maps.Keys(m.fields)) {
+			fmt.Fprintf(w, "    %s: %s\n", k, m.fields[k])
+		}
+	}
+	if info.Probe != nil {
+		var probe struct {
+			Format struct {
+				FormatName string `json:"format_name"`
+				Duration   string `json:"duration"`
+				BitRate    string `json:"bit_rate"`
+			} `json:"format"`
+			Streams []struct {
+				Index     int    `json:"index"`
+				CodecType string `json:"codec_type"`
+				CodecName string `json:"codec_name"`
+				Width     int    `json:"width"`
+				Height    int    `json:"height"`
+				FrameRate string `json:"r_frame_rate"`
+				Rate      string `json:"sample_rate"`
+				Channels  int    `json:"channels"`
+			} `json:"streams"`
+		}
+		if err := json.Unmarshal(info.Probe, &probe); err == nil {
+			fmt.Fprintln(w, "  ffprobe:")
+			fmt.Fprintf(w, "    format: %s duration: %s bit rate: %s\n", probe.Format.FormatName, probe.Format.Duration, probe.Format.BitRate)
+			for _, s := range probe.Streams {
+				switch s.CodecType {
+				case "video":
+					fmt.Fprintf(w, "    stream %d: %s %s %dx%d %s fps\n", s.Index, s.CodecType, s.CodecName, s.Width, s.Height, s.FrameRate)
+				case "audio":
+					fmt.Fprintf(w, "    stream %d: %s %s %s Hz %d channels\n", s.Index, s.CodecType, s.CodecName, s.Rate, s.Channels)
+				default:
+					fmt.Fprintf(w, "    stream %d: %s %s\n", s.Index, s.CodecType, s.CodecName)
+				}
+			}
+		}
+	}
+	field("error", info.Error)
+}
+
+// colorModel returns the name and bit depth per channel of the color model.
+func colorModel(m color.Model) (string, int) {
+	switch m {
+	case color.RGBAModel:
+		return "rgba", 8
+	case color.RGBA64Model:
+		return "rgba", 16
+	case color.NRGBAModel:
+		return "nrgba", 8
+	case color.NRGBA64Model:
+		return "nrgba", 16
+	case color.GrayModel:
+		return "gray", 8
+	case color.Gray16Model:
+		return "gray", 16
+	case color.AlphaModel:
+		return "alpha", 8
+	case color.Alpha16Model:
+		return "alpha", 16
+	case color.CMYKModel:
+		return "cmyk", 8
+	case color.YCbCrModel:
+		return "ycbcr", 8
+	case color.NYCbCrAModel:
+		return "nycbcra", 8
+	}
+	switch p, ok := m.(color.Palette); {
+	case ok && len(p) == 0: // ie, gifs without a global color table
+		return "paletted", 8
+	case ok:
+		return "paletted (" + strconv.Itoa(len(p)) + " colors)", 8
+	}
+	return fmt.Sprintf("%T", m), 0
+}
+
+// vipsColorModel returns the name and bit depth per channel of the vips
+// image's color model.
+func vipsColorModel(v *vips.Image) (string, int) {
+	var name string
+	switch v.Interpretation() {
+	case vips.InterpretationBW, vips.InterpretationGrey16:
+		name = "gray"
+	case vips.InterpretationSrgb, vips.InterpretationRgb, vips.InterpretationRgb16, vips.InterpretationScrgb:
+		name = "rgb"
+	case vips.InterpretationCmyk:
+		name = "cmyk"
+	case vips.InterpretationLab, vips.InterpretationLabq, vips.InterpretationLabs:
+		name = "lab"
+	default:
+		name = "multiband (" + strconv.Itoa(v.Bands()) + " bands)"
+	}
+	if v.HasAlpha() {
+		name += " with alpha"
+	}
+	var depth int
+	switch v.BandFormat() {
+	case vips.BandFormatUchar, vips.BandFormatChar:
+		depth = 8
+	case vips.BandFormatUshort, vips.BandFormatShort:
+		depth = 16
+	case vips.BandFormatUint, vips.BandFormatInt, vips.BandFormatFloat:
+		depth = 32
+	case vips.BandFormatDouble:
+		depth = 64
+	}
+	return name, depth
+}
+
+// formatCount formats n of total, omitting zero values.
+func formatCount(n, total int) string {
+	switch {
+	case n == 0:
+		return ""
+	case total == 0:
+		return strconv.Itoa(n)
+	}
+	return strconv.Itoa(n) + "/" + strconv.Itoa(total)
+}
+
+// parseXMP parses the properties of the xmp packet's rdf descriptions, with
+// array items joined by commas.
+func parseXMP(buf []byte) map[string]string {
+	if len(buf) == 0 {
+		return nil
+	}
+	fields := make(map[string]string)
+	add := func(name, s string) {
+		if s = strings.TrimSpace(s); s == "" {
+			return
+		}
+		if prev, ok := fields[name]; ok {
+			s = prev + ", " + s
+		}
+		fields[name] = s
+	}
+	d := xml.NewDecoder(bytes.NewReader(bytes.TrimRight(buf, "\x00")))
+	// stack of open elements below rdf:Description
+	var stack []string
+	var desc bool
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			break
+		}
+		switch x := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case x.Name.Space == rdfNS && x.Name.Local == "Description" && len(stack) == 0:
+				desc = true
+				for _, attr := range x.Attr {
+					if attr.Name.Space != rdfNS && attr.Name.Space != "xmlns" && attr.Name.Space != "" {
+						add(xmpName(attr.Name), attr.Value)
+					}
+				}
+			case desc:
+				stack = append(stack, xmpName(x.Name))
+			}
+		case xml.EndElement:
+			switch {
+			case len(stack) != 0:
+				stack = stack[:len(stack)-1]
+			case x.Name.Space == rdfNS && x.Name.Local == "Description":
+				desc = false
+			}
+		case xml.CharData:
+			if len(stack) != 0 {
+				add(stack[0], string(x))
+			}
+		}
+	}
+	return fields
+}
+
+// xmpName returns the prefixed name of the xmp property.
+func xmpName(name xml.Name) string {
+	prefix, ok := xmpPrefixes[name.Space]
+	if !ok {
+		prefix = path.Base(strings.TrimRight(name.Space, "/#"))
+	}
+	return prefix + ":" + name.Local
+}
+
+// parseIPTC parses the iptc application record datasets, from either raw
+// iptc data or a photoshop image resource block.
+func parseIPTC(buf []byte) map[string]string {
+	// find the iptc resource in photoshop image resource blocks
+	for i := bytes.Index(buf, []byte("8BIM")); i != -1 && i+8 <= len(buf); {
+		id, n := binary.BigEndian.Uint16(buf[i+4:]), int(buf[i+6])
+		// pascal string name, padded to even length
+		j := i + 6 + (n+2)&^1
+		if len(buf) < j+4 {
+			break
+		}
+		size := int(binary.BigEndian.Uint32(buf[j:]))
+		if len(buf) < j+4+size {
+			break
+		}
+		if id == 0x0404 {
+			buf = buf[j+4 : j+4+size]
+			break
+		}
+		k := bytes.Index(buf[j+4+size:], []byte("8BIM"))
+		if k == -1 {
+			break
+		}
+		i = j + 4 + size + k
+	}
+	fields := make(map[string]string)
+	for i := 0; i+5 <= len(buf) && buf[i] == 0x1c; {
+		record, dataset, n := buf[i+1], buf[i+2], int(binary.BigEndian.Uint16(buf[i+3:]))
+		// extended datasets are not supported
+		if n&0x8000 != 0 || len(buf) < i+5+n {
+			break
+		}
+		if name := iptcDatasets[dataset]; record == 2 && name != "" {
+			s := strings.TrimSpace(string(buf[i+5 : i+5+n]))
+			if prev, ok := fields[name]; ok {
+				s = prev + ", " + s
+			}
+			fields[name] = s
+		}
+		i += 5 + n
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// iccDescription returns the profile description of the icc profile.
+func iccDescription(buf []byte) string {
+	if len(buf) < 132 {
+		return ""
+	}
+	n := int(binary.BigEndian.Uint32(buf[128:]))
+	for i := 0; i < n && 132+i*12+12 <= len(buf); i++ {
+		entry := buf[132+i*12:]
+		if string(entry[:4]) != "desc" {
+			continue
+		}
+		off, size := int(binary.BigEndian.Uint32(entry[4:])), int(binary.BigEndian.Uint32(entry[8:]))
+		if off < 0 || size < 12 || len(buf) < off+size {
+			return ""
+		}
+		d := buf[off : off+size]
+		switch string(d[:4]) {
+		case "desc":
+			// ascii count and string
+			if n := int(binary.BigEndian.Uint32(d[8:])); 12+n <= len(d) {
+				return strings.TrimRight(string(d[12:12+n]), "\x00")
+			}
+		case "mluc":
+			// first localized utf-16 record
+			if len(d) < 28 {
+				return ""
+			}
+			l, o := int(binary.BigEndian.Uint32(d[20:])), int(binary.BigEndian.Uint32(d[24:]))
+			if o < 0 || l < 0 || len(d) < o+l {
+				return ""
+			}
+			u := make([]uint16, l/2)
+			for j := range u {
+				u[j] = binary.BigEndian.Uint16(d[o+j*2:])
+			}
+			return strings.TrimRight(string(utf16.Decode(u)), "\x00")
+		}
+		return ""
+	}
+	return ""
+}
+
+// rdfNS is the rdf namespace.
+const rdfNS = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+
+// xmpPrefixes are the common xmp namespace prefixes.
+var xmpPrefixes = map[string]string{
+	"http://purl.org/dc/elements/1.1/":             "dc",
+	"http://ns.adobe.com/xap/1.0/":                 "xmp",
+	"http://ns.adobe.com/xap/1.0/mm/":              "xmpMM",
+	"http://ns.adobe.com/xap/1.0/rights/":          "xmpRights",
+	"http://ns.adobe.com/exif/1.0/":                "exif",
+	"http://ns.adobe.com/tiff/1.0/":                "tiff",
+	"http://ns.adobe.com/photoshop/1.0/":           "photoshop",
+	"http://ns.adobe.com/camera-raw-settings/1.0/": "crs",
+	"http://ns.adobe.com/exif/1.0/aux/":            "aux",
+	"http://iptc.org/std/Iptc4xmpCore/1.0/xmlns/":  "Iptc4xmpCore",
+}
+
+// iptcDatasets are the names of the iptc application record datasets.
+var iptcDatasets = map[byte]string{
+	5:   "ObjectName",
+	25:  "Keywords",
+	40:  "SpecialInstructions",
+	55:  "DateCreated",
+	60:  "TimeCreated",
+	80:  "By-line",
+	85:  "By-lineTitle",
+	90:  "City",
+	95:  "Province-State",
+	101: "Country",
+	105: "Headline",
+	110: "Credit",
+	115: "Source",
+	116: "CopyrightNotice",
+	120: "Caption-Abstract",
+	122: "Writer-Editor",
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"maps"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestParseXMP(t *testing.T) {
+	const xmp = `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+  <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+    <rdf:Description rdf:about=""
+        xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+        xmlns:dc="http://purl.org/dc/elements/1.1/"
+        xmlns:foo="http://example.com/ns/foo/"
+        xmp:CreatorTool="Tool"
+        foo:Bar="baz">
+      <dc:subject>
+        <rdf:Bag>
+          <rdf:li>a</rdf:li>
+          <rdf:li> b </rdf:li>
+        </rdf:Bag>
+      </dc:subject>
+      <dc:title>
+        <rdf:Alt>
+          <rdf:li xml:lang="x-default">Title</rdf:li>
+        </rdf:Alt>
+      </dc:title>
+      <xmp:Rating>5</xmp:Rating>
+    </rdf:Description>
+    <rdf:Description rdf:about="" xmlns:xmp="http://ns.adobe.com/xap/1.0/" xmp:Label="Red" xmp:Rating="4"/>
+  </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>` + "\x00\x00"
+	tests := []struct {
+		s   string
+		exp map[string]string
+	}{
+		{"", nil},
+		{
+			xmp,
+			map[string]string{
+				"xmp:CreatorTool": "Tool",
+				"foo:Bar":         "baz",
+				"dc:subject":      "a, b",
+				"dc:title":        "Title",
+				"xmp:Rating":      "5, 4",
+				"xmp:Label":       "Red",
+			},
+		},
+		// truncated
+		{
+			xmp[:strings.Index(xmp, "<dc:title>")+20],
+			map[string]string{
+				"xmp:CreatorTool": "Tool",
+				"foo:Bar":         "baz",
+				"dc:subject":      "a, b",
+			},
+		},
+		// elements outside a description
+		{`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"><dc:title xmlns:dc="http://purl.org/dc/elements/1.1/">x</dc:title></rdf:RDF>`, map[string]string{}},
+		{"<", map[string]string{}},
+		{"\x00\x01\x02", map[string]string{}},
+	}
+	for i, test := range tests {
+		if fields := parseXMP([]byte(test.s)); !maps.Equal(fields, test.exp) || (fields == nil) != (test.exp == nil) {
+			t.Errorf("test %d expected %v, got: %v", i, test.exp, fields)
+		}
+	}
+}
+
+func TestParseIPTC(t *testing.T) {
+	raw := testJoin(
+		testIPTC(1, 90, "\x1b%G"),
+		testIPTC(2, 5, "Name"),
+		testIPTC(2, 25, "a"),
+		testIPTC(2, 25, " b "),
+		testIPTC(2, 200, "unknown"),
+		testIPTC(2, 116, "(c)"),
+	)
+	exp := map[string]string{"ObjectName": "Name", "Keywords": "a, b", "CopyrightNotice": "(c)"}
+	ps := testJoin(
+		[]byte("Photoshop 3.0\x00"),
+		testPSBlock(0x03ed, "", []byte("odd")),
+		testPSBlock(0x0404, "abc", raw),
+		testPSBlock(0x040c, "thumbnail", []byte("data")),
+	)
+	extended := testJoin(testIPTC(2, 5, "Name"), []byte{0x1c, 2, 25, 0x80, 0x04, 0, 0, 0, 1, 'x'}, testIPTC(2, 25, "a"))
+	tests := []struct {
+		buf []byte
+		exp map[string]string
+	}{
+		{nil, nil},
+		{raw, exp},
+		{ps, exp},
+		// even and odd length names
+		{testPSBlock(0x0404, "", raw), exp},
+		{testPSBlock(0x0404, "a", raw), exp},
+		{testPSBlock(0x0404, "ab", raw), exp},
+		{testJoin(testPSBlock(0x03ed, "x", []byte("8BIM")), testPSBlock(0x0404, "", raw)), exp},
+		// truncated
+		{raw[:len(raw)-2], map[string]string{"ObjectName": "Name", "Keywords": "a, b"}},
+		{raw[:3], nil},
+		{ps[:len(ps)-10], exp},
+		{ps[:len(ps)-30], nil},
+		{ps[:20], nil},
+		{[]byte("8BIM"), nil},
+		{testPSBlock(0x03ed, "", []byte("data")), nil},
+		{extended, map[string]string{"ObjectName": "Name"}},
+	}
+	for i, test := range tests {
+		if fields := parseIPTC(test.buf); !maps.Equal(fields, test.exp) || (fields == nil) != (test.exp == nil) {
+			t.Errorf("test %d expected %v, got: %v", i, test.exp, fields)
+		}
+	}
+}
+
+func TestICCDescription(t *testing.T) {
+	desc := testJoin([]byte("desc\x00\x00\x00\x00"), testUint32(18), []byte("sRGB IEC61966-2.1\x00"))
+	name := utf16.Encode([]rune("Display P3"))
+	mluc := testJoin([]byte("mluc\x00\x00\x00\x00"), testUint32(1), testUint32(12), []byte("enUS"), testUint32(uint32(len(name)*2)), testUint32(28))
+	for _, v := range name {
+		mluc = binary.BigEndian.AppendUint16(mluc, v)
+	}
+	tests := []struct {
+		buf []byte
+		exp string
+	}{
+		{nil, ""},
+		{testICC([]string{"desc"}, [][]byte{desc}), "sRGB IEC61966-2.1"},
+		{testICC([]string{"desc"}, [][]byte{mluc}), "Display P3"},
+		{testICC([]string{"cprt", "desc"}, [][]byte{[]byte("text\x00\x00\x00\x00(c)\x00"), mluc}), "Display P3"},
+		{testICC([]string{"cprt"}, [][]byte{desc}), ""},
+		{testICC([]string{"desc"}, [][]byte{[]byte("XYZ \x00\x00\x00\x00\x00\x00\x00\x00")}), ""},
+		// truncated
+		{testICC([]string{"desc"}, [][]byte{desc})[:131], ""},
+		{testICC([]string{"desc"}, [][]byte{desc})[:140], ""},
+		{testICC([]string{"desc"}, [][]byte{desc})[:150], ""},
+		{testICC([]string{"desc"}, [][]byte{desc[:8]}), ""},
+		{testICC([]string{"desc"}, [][]byte{testJoin(desc[:8], testUint32(100), []byte("short"))}), ""},
+		{testICC([]string{"desc"}, [][]byte{mluc[:20]}), ""},
+		{testICC([]string{"desc"}, [][]byte{mluc[:len(mluc)-2]}), ""},
+		{testICC([]string{"desc"}, [][]byte{testJoin(mluc[:24], testUint32(1<<30))}), ""},
+	}
+	for i, test := range tests {
+		if s := iccDescription(test.buf); s != test.exp {
+			t.Errorf("test %d expected %q, got: %q", i, test.exp, s)
+		}
+	}
+}
+
+// testIPTC returns an iptc dataset.
+func testIPTC(record, dataset byte, s string) []byte {
+	buf := []byte{0x1c, record, dataset}
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(s)))
+	return append(buf, s...)
+}
+
+// testPSBlock returns a photoshop image resource block, with the pascal
+// string name and data padded to even lengths.
+func testPSBlock(id uint16, name string, data []byte) []byte {
+	buf := binary.BigEndian.AppendUint16([]byte("8BIM"), id)
+	buf = append(append(buf, byte(len(name))), name...)
+	if len(name)%2 == 0 {
+		buf = append(buf, 0)
+	}
+	buf = append(append(buf, testUint32(uint32(len(data)))...), data...)
+	if len(data)%2 != 0 {
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+// testICC returns an icc profile with the tags.
+func testICC(sigs []string, data [][]byte) []byte {
+	buf := make([]byte, 128, 1024)
+	buf = append(buf, testUint32(uint32(len(sigs)))...)
+	off := len(buf) + len(sigs)*12
+	for i, sig := range sigs {
+		buf = append(buf, sig...)
+		buf = append(append(buf, testUint32(uint32(off))...), testUint32(uint32(len(data[i])))...)
+		off += len(data[i])
+	}
+	return append(buf, bytes.Join(data, nil)...)
+}
+
+// testUint32 returns v as big endian bytes.
+func testUint32(v uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, v)
+}
+
+// testJoin concatenates the byte slices.
+func testJoin(bufs ...[]byte) []byte {
+	return bytes.Join(bufs, nil)
+}
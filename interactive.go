@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// interactive displays the targets one at a time, navigating between targets
+// using keys read from the terminal.
+func (args *Args) interactive(w io.Writer, targets []target) error {
+	if len(targets) == 0 {
+		return errors.New("interactive: no targets")
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return errors.New("interactive: stdin is not a terminal")
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("interactive: %w", err)
+	}
+	defer term.Restore(fd, state)
+	// raw mode disables output processing
+	w = crlfWriter{w}
+	// switch to alternate screen, hide cursor
+	fmt.Fprint(w, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(w, "\x1b[?25h\x1b[?1049l")
+	defer args.clearScreen(w)
+	if args.images != nil {
+		defer args.images.removeAll(w, true)
+	}
+	// resize
+	resize := make(chan os.Signal, 1)
+	notifyResize(resize)
+	defer stopResize(resize)
+	// animations and videos would otherwise block navigation
+	args.Once, args.Loop, args.Play = true, false, false
+	// query the cell size before reading keys, as the terminal's reply
+	// would otherwise be read as keys
+	cellSize()
+	// keys
+	keys := make(chan key)
+	go readKeys(os.Stdin, keys)
+	for i, n, draw := 0, len(targets), true; ; {
+		if draw {
+			args.clearScreen(w)
+			args.setRegion()
+			if err := args.render(w, targets[i]); err != nil {
+				fmt.Fprintf(w, "error: render %q: %v\n", targets[i].path, err)
+			}
+			if !args.Quiet {
+				args.status(w, i, n)
+			}
+		}
+		prev := i
+		select {
+		case <-args.ctx.Done():
+			return args.ctx.Err()
+		case <-resize:
+			draw = true
+			continue
+		case k, ok := <-keys:
+			switch {
+			case !ok, k == keyQuit:
+				return nil
+			case k == keyNext, k == keyPause: // space pages forward
+				i = min(i+1, n-1)
+			case k == keyPrev:
+				i = max(i-1, 0)
+			case k == keyFirst:
+				i = 0
+			case k == keyLast:
+				i = n - 1
+			case k == keyRedraw:
+				prev = -1
+			}
+		}
+		draw = i != prev
+	}
+}
+
+// status writes the status line to the last row of the terminal.
+func (args *Args) status(w io.Writer, i, n int) {
+	_, rows, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "\x1b[%d;1H\x1b[7m %d/%d \x1b[0m n:next p:prev g:first G:last q:quit", rows, i+1, n)
+}
+
+// setRegion sets the region to place kitty images in, below the header and
+// above the status line.
+func (args *Args) setRegion() {
+	cols, rows, err := term.GetSize(int(os.Stdout.Fd()))
+	if args.images == nil || err != nil {
+		return
+	}
+	top := 2
+	if args.Quiet {
+		top = 1
+	}
+	args.region = image.Rect(1, top, cols+1, rows)
+}
+
+// clearScreen clears the terminal screen and any displayed images. Kitty
+// images are kept for when they are displayed again.
+func (args *Args) clearScreen(w io.Writer) {
+	if args.images != nil {
+		args.images.removeAll(w, false)
+	}
+	fmt.Fprint(w, "\x1b[2J\x1b[3J\x1b[H")
+}
+
+// key is a navigation key.
+type key int
+
+// Navigation keys.
+const (
+	keyNone key = iota
+	keyNext
+	keyPrev
+	keyFirst
+	keyLast
+	keyRedraw
+	keyPause
+	keyQuit
+)
+
+// readKeys reads keys from r, sending them to ch. Closes ch when r returns an
+// error.
+func readKeys(r io.Reader, ch chan<- key) {
+	defer close(ch)
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return
+		}
+		for b := buf[:n]; len(b) != 0; {
+			k, m := parseKey(b)
+			if b = b[m:]; k != keyNone {
+				ch <- k
+			}
+		}
+	}
+}
+
+// parseKey parses the first key in buf, returning the key and the number of
+// bytes consumed.
+func parseKey(buf []byte) (key, int) {
+	switch buf[0] {
+	case 'q', 'Q', 0x03, 0x04: // ctrl-c, ctrl-d
+		return keyQuit, 1
+	case 'n', 'j', 'l', '\r', '\n':
+		return keyNext, 1
+	case ' ':
+		return keyPause, 1
+	case 'p', 'k', 'h', 0x7f, 0x08: // backspace
+		return keyPrev, 1
+	case 'g':
+		return keyFirst, 1
+	case 'G':
+		return keyLast, 1
+	case 'r', 0x0c: // ctrl-l
+		return keyRedraw, 1
+	case 0x1b:
+	default:
+		return keyNone, 1
+	}
+	// escape
+	if len(buf) == 1 {
+		return keyQuit, 1
+	}
+	if buf[1] != '[' && buf[1] != 'O' {
+		return keyNone, 2
+	}
+	// control sequence
+	i := 2
+	for i < len(buf) && (('0' <= buf[i] && buf[i] <= '9') || buf[i] == ';') {
+		i++
+	}
+	if i == len(buf) {
+		return keyNone, i
+	}
+	switch string(buf[2 : i+1]) {
+	case "C", "B", "6~": // right, down, page down
+		return keyNext, i + 1
+	case "D", "A", "5~": // left, up, page up
+		return keyPrev, i + 1
+	case "H", "1~", "7~": // home
+		return keyFirst, i + 1
+	case "F", "4~", "8~": // end
+		return keyLast, i + 1
+	}
+	return keyNone, i + 1
+}
+
+// crlfWriter wraps a writer, translating newlines to carriage return and
+// newlines.
+type crlfWriter struct {
+	w io.Writer
+}
+
+// Write satisfies the [io.Writer] interface.
+func (w crlfWriter) Write(buf []byte) (int, error) {
+	if _, err := w.w.Write(bytes.ReplaceAll(buf, []byte("\n"), []byte("\r\n"))); err != nil {
+		return 0, err
+	}
+	return len(buf), nil
+}
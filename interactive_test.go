@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp key
+		n   int
+	}{
+		{"q", keyQuit, 1},
+		{"\x03", keyQuit, 1},
+		{"\x1b", keyQuit, 1},
+		{" ", keyPause, 1},
+		{"jq", keyNext, 1},
+		{"\r", keyNext, 1},
+		{"k", keyPrev, 1},
+		{"\x7f", keyPrev, 1},
+		{"g", keyFirst, 1},
+		{"G", keyLast, 1},
+		{"\x0c", keyRedraw, 1},
+		{"x", keyNone, 1},
+		{"\x1b[C", keyNext, 3},
+		{"\x1b[Aq", keyPrev, 3},
+		{"\x1bOB", keyNext, 3},
+		{"\x1b[6~", keyNext, 4},
+		{"\x1b[5~", keyPrev, 4},
+		{"\x1b[H", keyFirst, 3},
+		{"\x1b[1~", keyFirst, 4},
+		{"\x1b[F", keyLast, 3},
+		{"\x1b[4~", keyLast, 4},
+		{"\x1b[1;5C", keyNone, 6},
+		{"\x1b[2~", keyNone, 4},
+		{"\x1b[12", keyNone, 4},
+		{"\x1bx", keyNone, 2},
+	}
+	for i, test := range tests {
+		k, n := parseKey([]byte(test.s))
+		if k != test.exp || n != test.n {
+			t.Errorf("test %d %q expected %d %d, got: %d %d", i, test.s, test.exp, test.n, k, n)
+		}
+	}
+}
+
+func TestReadKeys(t *testing.T) {
+	ch := make(chan key)
+	go readKeys(bytes.NewReader([]byte("jx\x1b[Dg\x1b[6~q")), ch)
+	var keys []key
+	for k := range ch {
+		keys = append(keys, k)
+	}
+	if exp := []key{keyNext, keyPrev, keyFirst, keyNext, keyQuit}; !slices.Equal(keys, exp) {
+		t.Errorf("expected %v, got: %v", exp, keys)
+	}
+}
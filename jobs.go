@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"iter"
+	"sync"
+)
+
+// decodeAll decodes the targets concurrently using a bounded number of jobs,
+// yielding the decoded targets in order. As decoding records state on args,
+// each target is decoded using its own copy of args. At most jobs targets are
+// decoded ahead of the yielded target.
+func (args *Args) decodeAll(targets []target) iter.Seq[*decoded] {
+	return func(yield func(*decoded) bool) {
+		ctx, cancel := context.WithCancel(args.ctx)
+		defer cancel()
+		jobs := max(int(args.Jobs), 1)
+		args.logger("jobs: %d", jobs)
+		results := make([]chan *decoded, len(targets))
+		for i := range results {
+			results[i] = make(chan *decoded, 1)
+		}
+		sem := make(chan struct{}, jobs)
+		var wg sync.WaitGroup
+		wg.Go(func() {
+			for i, v := range targets {
+				select {
+				case <-ctx.Done():
+					return
+				case sem <- struct{}{}:
+				}
+				wg.Go(func() {
+					a := args.clone(ctx)
+					results[i] <- &decoded{
+						v:     v,
+						args:  a,
+						items: a.decodeTarget(v),
+					}
+				})
+			}
+		})
+		var next int
+		// stop decoding, and remove the temporary files of targets that were
+		// not yielded
+		defer func() {
+			cancel()
+			wg.Wait()
+			for _, ch := range results[next:] {
+				select {
+				case d := <-ch:
+					d.args.removeTemps()
+				default:
+				}
+			}
+		}()
+		for ; next < len(targets); next++ {
+			var d *decoded
+			select {
+			case <-ctx.Done():
+				return
+			case d = <-results[next]:
+			}
+			<-sem
+			ok := yield(d)
+			d.args.removeTemps()
+			if !ok {
+				next++
+				return
+			}
+		}
+	}
+}
+
+// decoded is a decoded target.
+type decoded struct {
+	v     target
+	args  *Args
+	items []item
+}
+
+// clone returns a copy of args using the context, for decoding a target.
+func (args *Args) clone(ctx context.Context) *Args {
+	a := *args
+	a.ctx, a.temps, a.saved = ctx, nil, nil
+	return &a
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image/color"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDecodeAll(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skipf("sh not available: %v", err)
+	}
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", dir)
+	running, log := filepath.Join(dir, "running"), filepath.Join(dir, "log")
+	if err := os.Mkdir(running, 0o755); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	// targets are decoded by a plugin recording the number of running
+	// decodes, with earlier targets taking longer
+	delays := []string{"0.3", "0.2", "0.1", "0.1", "0"}
+	var targets []target
+	for i, delay := range delays {
+		buf := new(bytes.Buffer)
+		img := testImage(i+1, 1, func(int, int) color.NRGBA { return color.NRGBA{0, 0, 0, 0xff} })
+		if err := png.Encode(buf, img); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		pathName := filepath.Join(dir, strconv.Itoa(i)+".slow")
+		if err := os.WriteFile(pathName, buf.Bytes(), 0o644); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if err := os.WriteFile(pathName+".delay", []byte(delay), 0o644); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		targets = append(targets, target{path: pathName})
+	}
+	script := `touch "$1/$$"; ls "$1" | wc -l >> "$2"; sleep $(cat "$3.delay"); rm "$1/$$"; cat "$3"`
+	p, err := newPlugin("slow", map[string][]string{
+		"ext":     {"slow"},
+		"command": {"sh", "-c", script, "sh", running, log, "{path}"},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	tests := []struct {
+		jobs uint
+		stop int
+	}{
+		{1, len(delays)},
+		{2, len(delays)},
+		{4, len(delays)},
+		{16, len(delays)},
+		{2, 1},
+	}
+	for i, test := range tests {
+		if err := os.Remove(log); err != nil && !os.IsNotExist(err) {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		args := &Args{
+			ctx:     context.Background(),
+			logger:  t.Logf,
+			NoCache: true,
+			Jobs:    test.jobs,
+			config:  &config{plugins: []*plugin{p}},
+		}
+		var n int
+		for d := range args.decodeAll(targets) {
+			// yielded in order
+			if d.v != targets[n] {
+				t.Errorf("test %d expected %s, got: %s", i, targets[n].path, d.v.path)
+			}
+			img, _, err := d.items[0].decode()
+			switch {
+			case err != nil:
+				t.Errorf("test %d %s expected no error, got: %v", i, d.v.path, err)
+			case img.Bounds().Dx() != n+1:
+				t.Errorf("test %d %s expected width %d, got: %d", i, d.v.path, n+1, img.Bounds().Dx())
+			}
+			if n++; n == test.stop {
+				break
+			}
+		}
+		if n != test.stop {
+			t.Errorf("test %d expected %d targets, got: %d", i, test.stop, n)
+		}
+		// all decodes finished when stopping early
+		if entries, err := os.ReadDir(running); err != nil || len(entries) != 0 {
+			t.Errorf("test %d expected no running decodes, got: %d %v", i, len(entries), err)
+		}
+		buf, err := os.ReadFile(log)
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		var most int
+		for _, s := range strings.Fields(string(buf)) {
+			v, err := strconv.Atoi(s)
+			if err != nil {
+				t.Fatalf("test %d expected no error, got: %v", i, err)
+			}
+			most = max(most, v)
+		}
+		switch exp := min(int(test.jobs), len(delays)); {
+		case most > exp:
+			t.Errorf("test %d expected at most %d running decodes, got: %d", i, exp, most)
+		case exp > 1 && most < 2:
+			t.Errorf("test %d expected concurrent decodes, got: %d", i, most)
+		}
+	}
+}
+
+func TestDecodeAllCancel(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skipf("sh not available: %v", err)
+	}
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", dir)
+	buf := new(bytes.Buffer)
+	img := testImage(1, 1, func(int, int) color.NRGBA { return color.NRGBA{0, 0, 0, 0xff} })
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	var targets []target
+	for i := range 4 {
+		pathName := filepath.Join(dir, strconv.Itoa(i)+".slow")
+		if err := os.WriteFile(pathName, buf.Bytes(), 0o644); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		targets = append(targets, target{path: pathName})
+	}
+	// all but the first target are slow to decode
+	p, err := newPlugin("slow", map[string][]string{
+		"ext":     {"slow"},
+		"command": {"sh", "-c", `case "$1" in *0.slow) ;; *) sleep 0.3 ;; esac; cat "$1"`, "sh", "{path}"},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	args := &Args{
+		ctx:     ctx,
+		logger:  t.Logf,
+		NoCache: true,
+		Jobs:    2,
+		config:  &config{plugins: []*plugin{p}},
+	}
+	var n int
+	for range args.decodeAll(targets) {
+		n++
+		cancel()
+	}
+	if n != 1 {
+		t.Errorf("expected 1 target, got: %d", n)
+	}
+}
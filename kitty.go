@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"hash/maphash"
+	"image"
+	"image/png"
+	"io"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// kittyImages are the images transmitted to the terminal using the kitty
+// graphics protocol. Images are transmitted once with an id in iv's id range,
+// and then placed (and placed again) by id. In tmux, images are placed with
+// unicode placeholders, which tmux redraws as text.
+type kittyImages struct {
+	passthrough func([]byte) []byte
+	// virtual places images with unicode placeholders
+	virtual bool
+	seed    maphash.Seed
+	// images are the transmitted images, by the hash of their pixels
+	images    map[uint64]*kittyImage
+	next      uint32
+	placement uint32
+}
+
+// kittyImage is an image transmitted to the terminal.
+type kittyImage struct {
+	id   uint32
+	hash uint64
+	size image.Point
+	// placements is the number of the image's placements
+	placements int
+}
+
+// newKittyImages creates the transmitted kitty images. Ids are allocated
+// from a random offset in iv's id range, so that images of other iv
+// processes are not replaced.
+func newKittyImages(virtual bool, passthrough func([]byte) []byte) *kittyImages {
+	return &kittyImages{
+		passthrough: passthrough,
+		virtual:     virtual,
+		seed:        maphash.MakeSeed(),
+		images:      make(map[uint64]*kittyImage),
+		next:        rand.Uint32N(kittyIDMax - kittyIDMin + 1),
+	}
+}
+
+// transmit transmits the image to the terminal, returning the previously
+// transmitted image when the image's pixels are the same.
+func (ki *kittyImages) transmit(w io.Writer, img image.Image) (*kittyImage, error) {
+	src := toNRGBA(img)
+	var h maphash.Hash
+	h.SetSeed(ki.seed)
+	fmt.Fprintf(&h, "%v", src.Rect)
+	h.Write(src.Pix)
+	hash := h.Sum64()
+	if k, ok := ki.images[hash]; ok {
+		return k, nil
+	}
+	k := &kittyImage{
+		id:   kittyIDMin + ki.next,
+		hash: hash,
+		size: src.Rect.Size(),
+	}
+	ki.next = (ki.next + 1) % (kittyIDMax - kittyIDMin + 1)
+	data := new(bytes.Buffer)
+	enc := base64.NewEncoder(base64.StdEncoding, data)
+	if err := png.Encode(enc, src); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	out := new(bytes.Buffer)
+	chunks := slices.Collect(slices.Chunk(data.Bytes(), 4096))
+	for i, chunk := range chunks {
+		m := 1
+		if i == len(chunks)-1 {
+			m = 0
+		}
+		if i == 0 {
+			fmt.Fprintf(out, "\x1b_Ga=t,f=100,q=2,i=%d,m=%d;%s\x1b\\", k.id, m, chunk)
+		} else {
+			fmt.Fprintf(out, "\x1b_Gm=%d;%s\x1b\\", m, chunk)
+		}
+	}
+	if _, err := w.Write(ki.passthrough(out.Bytes())); err != nil {
+		return nil, err
+	}
+	ki.images[hash] = k
+	return k, nil
+}
+
+// place places the image, returning the placement's id. The image is placed
+// at the cursor, moving the cursor to the line following the image, or when
+// col and row are not 0, at the cell position (1-based) without moving the
+// cursor. The image is scaled to cols and rows cells, or is not scaled when
+// cols and rows are 0. When only one of cols or rows is not 0, the other is
+// determined by the image's aspect ratio.
+func (ki *kittyImages) place(w io.Writer, k *kittyImage, col, row, cols, rows int) (uint32, error) {
+	ki.placement++
+	k.placements++
+	out := new(bytes.Buffer)
+	at := col != 0 || row != 0
+	if at {
+		// save the cursor, and move to the position
+		fmt.Fprintf(out, "\x1b7\x1b[%d;%dH", max(row, 1), max(col, 1))
+	}
+	if !ki.virtual {
+		seq := fmt.Sprintf("\x1b_Ga=p,q=2,i=%d,p=%d", k.id, ki.placement)
+		if cols != 0 {
+			seq += fmt.Sprintf(",c=%d", cols)
+		}
+		if rows != 0 {
+			seq += fmt.Sprintf(",r=%d", rows)
+		}
+		if at {
+			// restore the cursor, which is not moved by the placement
+			out.Write(ki.passthrough([]byte(seq + ",C=1\x1b\\")))
+			out.WriteString("\x1b8")
+		} else {
+			out.Write(ki.passthrough([]byte(seq + "\x1b\\")))
+			out.WriteByte('\n')
+		}
+		_, err := out.WriteTo(w)
+		return ki.placement, err
+	}
+	// virtual placement, and the placeholders, covering the cells of the
+	// image's size when not scaled
+	if cols == 0 || rows == 0 {
+		cw, ch, ok := cellSize()
+		if !ok {
+			cw, ch = 10, 20
+		}
+		c, r := (k.size.X+cw-1)/cw, (k.size.Y+ch-1)/ch
+		switch {
+		case cols == 0 && rows == 0:
+			cols, rows = c, r
+		case cols == 0:
+			cols = max(1, (rows*c+r/2)/max(r, 1))
+		default:
+			rows = max(1, (cols*r+c/2)/max(c, 1))
+		}
+	}
+	cols, rows = min(cols, len(kittyDiacritics)), min(rows, len(kittyDiacritics))
+	out.Write(ki.passthrough(fmt.Appendf(nil, "\x1b_Ga=p,U=1,q=2,i=%d,p=%d,c=%d,r=%d\x1b\\", k.id, ki.placement, cols, rows)))
+	// the foreground color is the id's lower 24 bits, the third diacritic
+	// is the id's upper 8 bits
+	fmt.Fprintf(out, "\x1b[38;2;%d;%d;%dm", k.id>>16&0xff, k.id>>8&0xff, k.id&0xff)
+	for i := range rows {
+		if at && i != 0 {
+			fmt.Fprintf(out, "\x1b[%d;%dH", max(row, 1)+i, max(col, 1))
+		}
+		for j := range cols {
+			out.WriteRune(kittyPlaceholder)
+			out.WriteRune(kittyDiacritics[i])
+			out.WriteRune(kittyDiacritics[j])
+			out.WriteRune(kittyDiacritics[k.id>>24])
+		}
+		if !at {
+			out.WriteByte('\n')
+		}
+	}
+	out.WriteString("\x1b[39m")
+	if at {
+		out.WriteString("\x1b8")
+	}
+	_, err := out.WriteTo(w)
+	return ki.placement, err
+}
+
+// remove removes the image's placement, or all of the image's placements
+// when placement is 0. The image is deleted from the terminal when free is
+// true.
+func (ki *kittyImages) remove(w io.Writer, k *kittyImage, placement uint32, free bool) error {
+	d := "i"
+	if free {
+		d = "I"
+		delete(ki.images, k.hash)
+	}
+	seq := fmt.Sprintf("\x1b_Ga=d,d=%s,q=2,i=%d", d, k.id)
+	if placement != 0 {
+		seq += fmt.Sprintf(",p=%d", placement)
+		k.placements = max(k.placements-1, 0)
+	} else {
+		k.placements = 0
+	}
+	_, err := w.Write(ki.passthrough([]byte(seq + "\x1b\\")))
+	return err
+}
+
+// removeAll removes the placements of all transmitted images. The images are
+// deleted from the terminal when free is true.
+func (ki *kittyImages) removeAll(w io.Writer, free bool) error {
+	for _, k := range ki.images {
+		if err := ki.remove(w, k, 0, free); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// release deletes the transmitted images that are no longer placed from the
+// terminal. Placed images are not deleted, as deleting them would remove
+// them from the screen.
+func (ki *kittyImages) release(w io.Writer) error {
+	for _, k := range ki.images {
+		if k.placements != 0 {
+			continue
+		}
+		if err := ki.remove(w, k, 0, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clear deletes all images in iv's id range from the terminal, including the
+// images of other iv processes.
+func (ki *kittyImages) clear(w io.Writer) error {
+	clear(ki.images)
+	seq := fmt.Sprintf("\x1b_Ga=d,d=R,q=2,x=%d,y=%d\x1b\\", kittyIDMin, kittyIDMax)
+	_, err := w.Write(ki.passthrough([]byte(seq)))
+	return err
+}
+
+// encodeKitty encodes the image to w using the kitty graphics protocol,
+// transmitting the image only when it was not previously transmitted.
+func (args *Args) encodeKitty(w io.Writer, img image.Image) error {
+	start := time.Now()
+	k, err := args.images.transmit(w, img)
+	if err != nil {
+		return err
+	}
+	// place at the top left of the region when set (ie, interactive mode),
+	// fitting the image to the region's cells
+	var col, row, cols, rows, height int
+	if r := args.region; !r.Empty() {
+		args.region = image.Rectangle{}
+		if cw, ch, ok := cellSize(); ok {
+			col, row, height = r.Min.X, r.Min.Y, (k.size.Y+ch-1)/ch
+			if cols, rows = fitCells(k.size, cw, ch, r.Dx(), r.Dy()); rows != 0 {
+				height = rows
+			}
+		}
+	}
+	if _, err := args.images.place(w, k, col, row, cols, rows); err != nil {
+		return err
+	}
+	if row != 0 {
+		// move the cursor to the line following the image
+		fmt.Fprintf(w, "\x1b[%d;1H", row+height)
+	}
+	args.logger("kitty encode (id: %d): %v", k.id, time.Since(start))
+	return nil
+}
+
+// fitCells returns the cells to scale an image of the size to, so that it
+// fits within cols and rows cells of cw and ch pixels, or 0 and 0 when it
+// already fits.
+func fitCells(size image.Point, cw, ch, cols, rows int) (int, int) {
+	c, r := (size.X+cw-1)/cw, (size.Y+ch-1)/ch
+	if c <= cols && r <= rows {
+		return 0, 0
+	}
+	return fitSize(c, r, cols, rows)
+}
+
+// clearImages removes all images displayed by iv from the terminal.
+func (args *Args) clearImages(w io.Writer) error {
+	if args.images == nil {
+		return fmt.Errorf("clear: kitty graphics not available")
+	}
+	return args.images.clear(w)
+}
+
+// iv's kitty image id range. The upper 16 bits are "iv".
+const (
+	kittyIDMin = 0x69760000
+	kittyIDMax = 0x6976ffff
+)
+
+// kittyPlaceholder is the kitty unicode placeholder character.
+const kittyPlaceholder = '\U0010EEEE'
+
+// kittyDiacritics are the kitty unicode placeholder row and column diacritics.
+var kittyDiacritics = [...]rune{
+	0x0305, 0x030d, 0x030e, 0x0310, 0x0312, 0x033d, 0x033e, 0x033f,
+	0x0346, 0x034a, 0x034b, 0x034c, 0x0350, 0x0351, 0x0352, 0x0357,
+	0x035b, 0x0363, 0x0364, 0x0365, 0x0366, 0x0367, 0x0368, 0x0369,
+	0x036a, 0x036b, 0x036c, 0x036d, 0x036e, 0x036f, 0x0483, 0x0484,
+	0x0485, 0x0486, 0x0487, 0x0592, 0x0593, 0x0594, 0x0595, 0x0597,
+	0x0598, 0x0599, 0x059c, 0x059d, 0x059e, 0x059f, 0x05a0, 0x05a1,
+	0x05a8, 0x05a9, 0x05ab, 0x05ac, 0x05af, 0x05c4, 0x0610, 0x0611,
+	0x0612, 0x0613, 0x0614, 0x0615, 0x0616, 0x0617, 0x0657, 0x0658,
+	0x0659, 0x065a, 0x065b, 0x065d, 0x065e, 0x06d6, 0x06d7, 0x06d8,
+	0x06d9, 0x06da, 0x06db, 0x06dc, 0x06df, 0x06e0, 0x06e1, 0x06e2,
+	0x06e4, 0x06e7, 0x06e8, 0x06eb, 0x06ec, 0x0730, 0x0732, 0x0733,
+	0x0735, 0x0736, 0x073a, 0x073d, 0x073f, 0x0740, 0x0741, 0x0743,
+	0x0745, 0x0747, 0x0749, 0x074a, 0x07eb, 0x07ec, 0x07ed, 0x07ee,
+	0x07ef, 0x07f0, 0x07f1, 0x07f3, 0x0816, 0x0817, 0x0818, 0x0819,
+	0x081b, 0x081c, 0x081d, 0x081e, 0x081f, 0x0820, 0x0821, 0x0822,
+	0x0823, 0x0825, 0x0826, 0x0827, 0x0829, 0x082a, 0x082b, 0x082c,
+	0x082d, 0x0951, 0x0953, 0x0954, 0x0f82, 0x0f83, 0x0f86, 0x0f87,
+	0x135d, 0x135e, 0x135f, 0x17dd, 0x193a, 0x1a17, 0x1a75, 0x1a76,
+	0x1a77, 0x1a78, 0x1a79, 0x1a7a, 0x1a7b, 0x1a7c, 0x1b6b, 0x1b6d,
+	0x1b6e, 0x1b6f, 0x1b70, 0x1b71, 0x1b72, 0x1b73, 0x1cd0, 0x1cd1,
+	0x1cd2, 0x1cda, 0x1cdb, 0x1ce0, 0x1dc0, 0x1dc1, 0x1dc3, 0x1dc4,
+	0x1dc5, 0x1dc6, 0x1dc7, 0x1dc8, 0x1dc9, 0x1dcb, 0x1dcc, 0x1dd1,
+	0x1dd2, 0x1dd3, 0x1dd4, 0x1dd5, 0x1dd6, 0x1dd7, 0x1dd8, 0x1dd9,
+	0x1dda, 0x1ddb, 0x1ddc, 0x1ddd, 0x1dde, 0x1ddf, 0x1de0, 0x1de1,
+	0x1de2, 0x1de3, 0x1de4, 0x1de5, 0x1de6, 0x1dfe, 0x20d0, 0x20d1,
+	0x20d4, 0x20d5, 0x20d6, 0x20d7, 0x20db, 0x20dc, 0x20e1, 0x20e7,
+	0x20e9, 0x20f0, 0x2cef, 0x2cf0, 0x2cf1, 0x2de0, 0x2de1, 0x2de2,
+	0x2de3, 0x2de4, 0x2de5, 0x2de6, 0x2de7, 0x2de8, 0x2de9, 0x2dea,
+	0x2deb, 0x2dec, 0x2ded, 0x2dee, 0x2def, 0x2df0, 0x2df1, 0x2df2,
+	0x2df3, 0x2df4, 0x2df5, 0x2df6, 0x2df7, 0x2df8, 0x2df9, 0x2dfa,
+	0x2dfb, 0x2dfc, 0x2dfd, 0x2dfe, 0x2dff, 0xa66f, 0xa67c, 0xa67d,
+	0xa6f0, 0xa6f1, 0xa8e0, 0xa8e1, 0xa8e2, 0xa8e3, 0xa8e4, 0xa8e5,
+	0xa8e6, 0xa8e7, 0xa8e8, 0xa8e9, 0xa8ea, 0xa8eb, 0xa8ec, 0xa8ed,
+	0xa8ee, 0xa8ef, 0xa8f0, 0xa8f1, 0xaab0, 0xaab2, 0xaab3, 0xaab7,
+	0xaab8, 0xaabe, 0xaabf, 0xaac1, 0xfe20, 0xfe21, 0xfe22, 0xfe23,
+	0xfe24, 0xfe25, 0xfe26, 0x10a0f, 0x10a38, 0x1d185, 0x1d186, 0x1d187,
+	0x1d188, 0x1d189, 0x1d1aa, 0x1d1ab, 0x1d1ac, 0x1d1ad, 0x1d242, 0x1d243,
+	0x1d244,
+}
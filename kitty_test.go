@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestKittyTransmit(t *testing.T) {
+	ki := newKittyImages(false, func(buf []byte) []byte { return buf })
+	ki.next = kittyIDMax - kittyIDMin
+	red := testImage(4, 4, func(int, int) color.NRGBA { return color.NRGBA{0xff, 0, 0, 0xff} })
+	blue := testImage(4, 4, func(int, int) color.NRGBA { return color.NRGBA{0, 0, 0xff, 0xff} })
+	tests := []struct {
+		img  image.Image
+		id   uint32
+		sent bool
+	}{
+		{red, kittyIDMax, true},
+		{red, kittyIDMax, false},
+		{image.NewRGBA(image.Rect(0, 0, 4, 4)), kittyIDMin, true},
+		{blue, kittyIDMin + 1, true},
+		{testImage(4, 4, func(int, int) color.NRGBA { return color.NRGBA{0xff, 0, 0, 0xff} }), kittyIDMax, false},
+	}
+	for i, test := range tests {
+		buf := new(bytes.Buffer)
+		k, err := ki.transmit(buf, test.img)
+		switch {
+		case err != nil:
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		case k.id != test.id:
+			t.Errorf("test %d expected id %d, got: %d", i, test.id, k.id)
+		case test.sent && !strings.HasPrefix(buf.String(), fmt.Sprintf("\x1b_Ga=t,f=100,q=2,i=%d,m=0;", test.id)):
+			t.Errorf("test %d expected transmission, got: %q", i, buf.String())
+		case !test.sent && buf.Len() != 0:
+			t.Errorf("test %d expected no transmission, got: %q", i, buf.String())
+		}
+	}
+}
+
+func TestKittyPlace(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 25, 45))
+	tests := []struct {
+		virtual              bool
+		col, row, cols, rows int
+		exp                  string
+	}{
+		{false, 0, 0, 0, 0, "\x1b_Ga=p,q=2,i=%[1]d,p=1\x1b\\\n"},
+		{false, 0, 0, 10, 5, "\x1b_Ga=p,q=2,i=%[1]d,p=1,c=10,r=5\x1b\\\n"},
+		{false, 0, 0, 0, 5, "\x1b_Ga=p,q=2,i=%[1]d,p=1,r=5\x1b\\\n"},
+		{false, 2, 3, 0, 0, "\x1b7\x1b[3;2H\x1b_Ga=p,q=2,i=%[1]d,p=1,C=1\x1b\\\x1b8"},
+		{false, 1, 2, 4, 3, "\x1b7\x1b[2;1H\x1b_Ga=p,q=2,i=%[1]d,p=1,c=4,r=3,C=1\x1b\\\x1b8"},
+		{false, 5, 0, 0, 0, "\x1b7\x1b[1;5H\x1b_Ga=p,q=2,i=%[1]d,p=1,C=1\x1b\\\x1b8"},
+		// placeholders are 10x20 pixel cells when the cell size is not known
+		{true, 0, 0, 0, 0, "\x1b_Ga=p,U=1,q=2,i=%[1]d,p=1,c=3,r=3\x1b\\\x1b[38;2;118;0;0m%[2]s\n%[3]s\n%[4]s\n\x1b[39m"},
+		{true, 0, 0, 2, 1, "\x1b_Ga=p,U=1,q=2,i=%[1]d,p=1,c=2,r=1\x1b\\\x1b[38;2;118;0;0m%[5]s\n\x1b[39m"},
+		{true, 2, 3, 0, 2, "\x1b7\x1b[3;2H\x1b_Ga=p,U=1,q=2,i=%[1]d,p=1,c=2,r=2\x1b\\\x1b[38;2;118;0;0m%[5]s\x1b[4;2H%[6]s\x1b[39m\x1b8"},
+	}
+	for i, test := range tests {
+		ki := newKittyImages(test.virtual, func(buf []byte) []byte { return buf })
+		ki.next = 0
+		k, err := ki.transmit(new(bytes.Buffer), img)
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		buf := new(bytes.Buffer)
+		placement, err := ki.place(buf, k, test.col, test.row, test.cols, test.rows)
+		switch {
+		case err != nil:
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		case placement != 1 || k.placements != 1:
+			t.Errorf("test %d expected placement 1, got: %d (%d placements)", i, placement, k.placements)
+		}
+		exp := fmt.Sprintf(test.exp, k.id,
+			testPlaceholders(k.id, 0, 3), testPlaceholders(k.id, 1, 3), testPlaceholders(k.id, 2, 3),
+			testPlaceholders(k.id, 0, 2), testPlaceholders(k.id, 1, 2),
+		)
+		if s := buf.String(); s != exp {
+			t.Errorf("test %d expected:\n%q\ngot:\n%q", i, exp, s)
+		}
+	}
+}
+
+func TestKittyRemove(t *testing.T) {
+	ki := newKittyImages(false, func(buf []byte) []byte { return buf })
+	ki.next = 0
+	a, _ := ki.transmit(new(bytes.Buffer), image.NewNRGBA(image.Rect(0, 0, 1, 1)))
+	b, _ := ki.transmit(new(bytes.Buffer), image.NewNRGBA(image.Rect(0, 0, 2, 2)))
+	buf := new(bytes.Buffer)
+	for _, k := range []*kittyImage{a, a, b} {
+		if _, err := ki.place(buf, k, 0, 0, 0, 0); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	tests := []struct {
+		f    func(*bytes.Buffer) error
+		exp  string
+		a, b int
+		n    int
+	}{
+		// removing a placement keeps the image
+		{func(buf *bytes.Buffer) error { return ki.remove(buf, a, 1, false) }, "\x1b_Ga=d,d=i,q=2,i={a},p=1\x1b\\", 1, 1, 2},
+		// placed images are not released
+		{func(buf *bytes.Buffer) error { return ki.release(buf) }, "", 1, 1, 2},
+		{func(buf *bytes.Buffer) error { return ki.remove(buf, b, 0, false) }, "\x1b_Ga=d,d=i,q=2,i={b}\x1b\\", 1, 0, 2},
+		{func(buf *bytes.Buffer) error { return ki.release(buf) }, "\x1b_Ga=d,d=I,q=2,i={b}\x1b\\", 1, 0, 1},
+		{func(buf *bytes.Buffer) error { return ki.remove(buf, a, 2, true) }, "\x1b_Ga=d,d=I,q=2,i={a},p=2\x1b\\", 0, 0, 0},
+		{func(buf *bytes.Buffer) error { return ki.clear(buf) }, "\x1b_Ga=d,d=R,q=2,x=1769340928,y=1769406463\x1b\\", 0, 0, 0},
+	}
+	for i, test := range tests {
+		buf := new(bytes.Buffer)
+		if err := test.f(buf); err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		exp := strings.NewReplacer("{a}", fmt.Sprint(a.id), "{b}", fmt.Sprint(b.id)).Replace(test.exp)
+		if buf.String() != exp {
+			t.Errorf("test %d expected %q, got: %q", i, exp, buf.String())
+		}
+		if a.placements != test.a || b.placements != test.b || len(ki.images) != test.n {
+			t.Errorf("test %d expected %d %d placements and %d images, got: %d %d and %d", i, test.a, test.b, test.n, a.placements, b.placements, len(ki.images))
+		}
+	}
+	// ids are not reused until the range wraps
+	c, _ := ki.transmit(new(bytes.Buffer), image.NewNRGBA(image.Rect(0, 0, 1, 1)))
+	if c.id != kittyIDMin+2 {
+		t.Errorf("expected id %d, got: %d", kittyIDMin+2, c.id)
+	}
+}
+
+func TestFitCells(t *testing.T) {
+	tests := []struct {
+		size       image.Point
+		cols, rows int
+		c, r       int
+	}{
+		{image.Pt(100, 100), 10, 5, 0, 0},
+		{image.Pt(95, 81), 10, 5, 0, 0},
+		{image.Pt(200, 100), 10, 5, 10, 2},
+		{image.Pt(100, 200), 10, 5, 5, 5},
+		{image.Pt(1000, 1000), 80, 22, 44, 22},
+	}
+	for i, test := range tests {
+		if c, r := fitCells(test.size, 10, 20, test.cols, test.rows); c != test.c || r != test.r {
+			t.Errorf("test %d expected %dx%d, got: %dx%d", i, test.c, test.r, c, r)
+		}
+	}
+}
+
+// testPlaceholders returns the kitty unicode placeholders for the row.
+func testPlaceholders(id uint32, row, cols int) string {
+	var sb strings.Builder
+	for col := range cols {
+		sb.WriteRune(kittyPlaceholder)
+		sb.WriteRune(kittyDiacritics[row])
+		sb.WriteRune(kittyDiacritics[col])
+		sb.WriteRune(kittyDiacritics[id>>24])
+	}
+	return sb.String()
+}
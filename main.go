@@ -3,6 +3,7 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/base64"
@@ -20,10 +21,12 @@ import (
 	"net/url"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path"
 	"path/filepath"
+	"reflect"
 	"regexp"
-	"sort"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -64,25 +67,75 @@ func main() {
 	args := &Args{
 		logger: func(string, ...any) {},
 	}
+	diffArgs := new(DiffArgs)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 	ox.RunContext(
-		context.Background(),
+		ctx,
+		ox.Args(fileArgs(os.Args[1:])...),
 		ox.Usage(name, "the command-line terminal graphics image viewer"),
 		ox.VersionString(version),
 		ox.Defaults(),
 		ox.Exec(run(os.Stdout, args)),
+		// keep --version, as sub commands replace it with a version command
+		ox.Flags().Hook("version", "show version, then exit", ox.DefaultVersion).Option(),
 		ox.From(args),
+		ox.Sub(
+			ox.Usage("diff", "compare two images"),
+			ox.Exec(runDiff(os.Stdout, args, diffArgs)),
+			ox.From(diffArgs),
+		),
 	)
 }
 
+// fileArgs returns the command-line arguments, prefixing the arguments
+// before "--" that are both a sub command name and an existing file with
+// "./", so that the file is opened instead of running the sub command (ex:
+// iv help).
+func fileArgs(cliargs []string) []string {
+	cliargs = slices.Clone(cliargs)
+	for i, s := range cliargs {
+		if s == "--" {
+			break
+		}
+		if !slices.Contains(subCommands, s) {
+			continue
+		}
+		if _, err := os.Stat(s); err == nil {
+			cliargs[i] = "./" + s
+		}
+	}
+	return cliargs
+}
+
+// subCommands are the sub command names.
+var subCommands = []string{"completion", "diff", "help", "version"}
+
 type Args struct {
 	Verbose         bool               `ox:"enable verbose,short:v"`
 	Quiet           bool               `ox:"enable quiet,short:q"`
+	Interactive     bool               `ox:"interactive mode,short:i"`
+	Info            bool               `ox:"show image information"`
+	JSON            bool               `ox:"show image information as json,name:json"`
+	InfoImage       bool               `ox:"display image with information"`
+	Recursive       bool               `ox:"recurse into directories,short:r"`
+	MaxDepth        uint               `ox:"maximum directory recursion depth"`
+	Include         []string           `ox:"include files matching glob"`
+	Exclude         []string           `ox:"exclude files matching glob"`
+	FollowSymlinks  bool               `ox:"follow symlinked directories,short:L"`
+	NoHidden        bool               `ox:"skip hidden files and directories"`
+	Loop            bool               `ox:"loop animations forever"`
+	Once            bool               `ox:"play animations once"`
+	Grid            bool               `ox:"display as thumbnail grid,short:g"`
+	GridSize        uint               `ox:"grid cell size,default:160"`
+	GridCols        uint               `ox:"grid columns"`
 	Width           uint               `ox:"display width,short:W"`
 	Height          uint               `ox:"display height,short:H"`
 	MinWidth        uint               `ox:"minimum width,short:w,default:64"`
 	MinHeight       uint               `ox:"minimum height,short:h,default:64"`
 	DPI             uint               `ox:"image dpi,default:300,name:dpi"`
 	Page            uint               `ox:"page to display,short:p"`
+	Pages           string             `ox:"page ranges to display (ex: 1-3\\,7\\,last)"`
 	Fg              *colors.Color      `ox:"foregrond color,default:dimgray"`
 	Bg              *colors.Color      `ox:"background color,default:transparent"`
 	Border          uint               `ox:"border width,default:30"`
@@ -93,14 +146,77 @@ type Args struct {
 	FontBg          *colors.Color      `ox:"font preview background color,default:white"`
 	FontDPI         uint               `ox:"font preview dpi,default:100,name:font-dpi"`
 	FontMargin      uint               `ox:"font preview margin,default:5"`
-	TimeCode        time.Duration      `ox:"video time code,short:t"`
+	TimeCode        *duration          `ox:"video time code,short:t,type:duration"`
+	Storyboard      uint               `ox:"video storyboard frame count"`
+	Play            bool               `ox:"play video"`
+	PlayLimit       *duration          `ox:"video play duration limit,type:duration"`
 	VipsConcurrency uint               `ox:"vips concurrency,default:$NUMCPU"`
 	MermaidIcons    []string           `ox:"additional mermaid icon packages"`
 	MermaidBg       *colors.Color      `ox:"default mermaid background,default:white"`
 	ForceMime       string             `ox:"force mime type"`
+	HTTPTimeout     uint               `ox:"http timeout in seconds,default:30,name:http-timeout"`
+	HTTPMaxSize     uint               `ox:"http max download size in MiB,default:100,name:http-max-size"`
+	HTTPRedirects   uint               `ox:"http max redirects,default:10,name:http-redirects"`
+	NoScale         bool               `ox:"disable scaling to fit the terminal"`
+	NoAutorotate    bool               `ox:"disable exif autorotation"`
+	Header          []string           `ox:"http header (name: value),short:A"`
+	Jobs            uint               `ox:"parallel decode jobs,short:j,default:$NUMCPU"`
+	NoCache         bool               `ox:"disable render cache"`
+	CacheSize       uint               `ox:"render cache max size in MiB,default:512"`
+	ClearCache      bool               `ox:"clear render cache"`
+	Output          string             `ox:"output file (png/jpeg/webp),short:o"`
+	ANSI            bool               `ox:"render using ansi text,name:ansi"`
+	Protocol        string             `ox:"graphics protocol (sixel/kitty/iterm/ansi)"`
+	Probe           bool               `ox:"show detected graphics protocols"`
+	Braille         bool               `ox:"render ansi text using braille characters"`
+	Clear           bool               `ox:"clear images displayed by iv (kitty)"`
+	Crop            string             `ox:"crop region (WxH+X+Y\\, pixels or percent)"`
+	Rotate          float64            `ox:"rotate degrees clockwise"`
+	Flip            bool               `ox:"flip vertically"`
+	Flop            bool               `ox:"flip horizontally"`
+	Zoom            float64            `ox:"zoom factor,default:1"`
+	Grayscale       bool               `ox:"convert to grayscale"`
+	Invert          bool               `ox:"invert colors"`
+	Gamma           float64            `ox:"gamma correction,default:1"`
+	Brightness      float64            `ox:"brightness adjustment (-100 to 100)"`
+	Contrast        float64            `ox:"contrast adjustment (-100 to 100)"`
+	Saturation      float64            `ox:"saturation factor,default:1"`
+	Channel         string             `ox:"show a single channel (r/g/b/a)"`
+	Histogram       bool               `ox:"show color histogram and statistics"`
 
 	ctx    context.Context
 	logger func(string, ...any)
+	// pages is the page count of the last decoded multi-page document
+	pages int
+	// temps are temporary files to remove after rendering
+	temps []string
+	// file and decoderName are the path and decoder of the last decoded file
+	file        string
+	decoderName string
+	// encrypted is set when the last decoded file required a password
+	encrypted bool
+	// converted is the multi-page intermediate file converted from the last
+	// decoded file, used to decode the file's other pages
+	converted *converted
+	// pageRanges are the parsed page ranges
+	pageRanges []pageRange
+	// protocol is the terminal graphics protocol
+	protocol rasterm.TermType
+	// mux is the terminal multiplexer
+	mux string
+	// images are the images transmitted using the kitty graphics protocol
+	images *kittyImages
+	// region is the cell region (1-based) to place the next kitty image in
+	region image.Rectangle
+	// diff are the diff command's arguments, when comparing images
+	diff *DiffArgs
+	// config is the user configuration
+	config *config
+	// fixed are the options set by flags or environment variables
+	fixed map[string]bool
+	// saved are the option values replaced by the configuration of the last
+	// decoded file's mime type or extension
+	saved map[int]reflect.Value
 
 	bgc  *color.NRGBA
 	mbgc *color.NRGBA
@@ -110,68 +226,126 @@ type Args struct {
 func run(w io.Writer, args *Args) func(context.Context, []string) error {
 	return func(ctx context.Context, cliargs []string) error {
 		args.ctx = ctx
-		if !rasterm.Available() {
-			return rasterm.ErrTermGraphicsNotAvailable
+		stdout := w
+		if err := args.loadConfig(ctx); err != nil {
+			return err
+		}
+		if args.ClearCache {
+			return clearCache()
+		}
+		// set protocol
+		switch typ, ok := protocols[strings.ToLower(args.Protocol)]; {
+		case args.Probe:
+			return args.probe(w)
+		case !ok:
+			return fmt.Errorf("invalid protocol %q", args.Protocol)
+		case typ == rasterm.None:
+			args.ANSI = true
+		default:
+			args.protocol = typ
 		}
+		// export when an output file was specified, or stdout is redirected
+		// to a file and graphics were not forced
+		info := args.Info || args.JSON
+		args.ANSI = args.ANSI || args.Braille
+		forced := args.ANSI || args.protocol != rasterm.Default || os.Getenv("TERM_GRAPHICS") != ""
+		export := !info && !args.Clear && (args.Output != "" || !forced && isRegularFile(os.Stdout))
 		// set verbose logger
 		if args.Verbose {
 			args.logger = func(s string, v ...any) {
 				fmt.Fprintf(os.Stderr, s+"\n", v...)
 			}
 		}
-		// set svg background color and scaling
-		resvg.WithBackground(args.Bg)(resvg.Default)
-		if args.Width != 0 || args.Height != 0 {
-			resvg.WithScaleMode(resvg.ScaleBestFit)(resvg.Default)
-			resvg.WithWidth(max(int(args.Width), int(args.MinWidth)))(resvg.Default)
-			resvg.WithHeight(max(int(args.Height), int(args.MinHeight)))(resvg.Default)
+		switch {
+		case export:
+			w = os.Stderr
+		case info && (args.JSON || !args.InfoImage), args.ANSI, args.protocol != rasterm.Default:
+		case !term.IsTerminal(int(os.Stdout.Fd())), !rasterm.Available():
+			// fallback to ansi text, such as when piped or in ci logs
+			args.logger("terminal graphics not available, using ansi")
+			args.ANSI = true
+		}
+		// wrap graphics for the terminal multiplexer
+		if !args.ANSI && !export {
+			if args.mux = multiplexer(); args.mux != "" {
+				args.logger("multiplexer: %s", args.mux)
+			}
+		}
+		// reuse kitty images by id
+		if !args.ANSI && !export && args.kitty() {
+			args.images = newKittyImages(args.mux == "tmux", args.passthrough)
+			defer args.images.release(w)
+		}
+		if args.Clear {
+			return args.clearImages(w)
+		}
+		// check patterns
+		if err := checkPatterns(append(args.Include, args.Exclude...)...); err != nil {
+			return err
 		}
-		// convert/cache background colors
-		if !colors.Is(args.Bg, colors.Transparent) {
-			c := args.Bg.NRGBA()
-			args.bgc = &c
+		// parse page ranges
+		if args.Pages != "" {
+			var err error
+			if args.pageRanges, err = parsePages(args.Pages); err != nil {
+				return err
+			}
 		}
-		if !colors.Is(args.MermaidBg, colors.Transparent) {
-			c := args.MermaidBg.NRGBA()
-			args.mbgc = &c
+		// read from stdin when not a terminal
+		if len(cliargs) == 0 && !term.IsTerminal(int(os.Stdin.Fd())) {
+			cliargs = []string{"-"}
 		}
 		// collect targets
 		var targets []target
 		for _, pathName := range cliargs {
-			if v, err := open(pathName); err == nil {
+			if v, err := args.open(pathName); err == nil {
 				targets = append(targets, v...)
 			} else {
 				fmt.Fprintf(w, "error: %v\n\n", err)
 			}
 		}
 		// render
-		for _, v := range targets {
-			if err := args.render(w, v); err != nil {
-				fmt.Fprintf(w, "error: render %q: %v\n\n", v.path, err)
+		switch {
+		case args.diff != nil:
+			return args.compare(stdout, targets, !export)
+		case info:
+			return args.showInfo(w, targets)
+		case export:
+			return args.export(w, targets)
+		case args.Interactive:
+			return args.interactive(w, targets)
+		case args.Grid:
+			return args.renderGrid(w, targets)
+		}
+		for d := range args.decodeAll(targets) {
+			if err := d.args.display(w, d.v, d.items); err != nil {
+				fmt.Fprintf(w, "error: render %q: %v\n\n", d.v.path, err)
 			}
 		}
 		return nil
 	}
 }
 
+// initColors converts and caches the background colors.
+func (args *Args) initColors() {
+	args.bgc, args.mbgc = nil, nil
+	if !colors.Is(args.Bg, colors.Transparent) {
+		c := args.Bg.NRGBA()
+		args.bgc = &c
+	}
+	if !colors.Is(args.MermaidBg, colors.Transparent) {
+		c := args.MermaidBg.NRGBA()
+		args.mbgc = &c
+	}
+}
+
 // open returns the files to open.
-func open(pathName string) ([]target, error) {
+func (args *Args) open(pathName string) ([]target, error) {
+	if pathName == "-" {
+		return []target{{path: pathName}}, nil
+	}
 	switch fi, err := os.Stat(pathName); {
 	case err == nil && fi.IsDir():
-		entries, err := os.ReadDir(pathName)
-		if err != nil {
-			return nil, err
-		}
-		var d []target
-		for _, entry := range entries {
-			if s := entry.Name(); !entry.IsDir() && extensions[fileExt(s)] {
-				d = append(d, target{path: filepath.Join(pathName, s)})
-			}
-		}
-		sort.Slice(d, func(i, j int) bool {
-			return d[i].path < d[j].path
-		})
-		return d, nil
+		return args.walk(pathName)
 	case err == nil:
 		return []target{{path: pathName}}, nil
 	case strings.Contains(pathName, "://"):
@@ -180,13 +354,14 @@ func open(pathName string) ([]target, error) {
 		}
 	case
 		strings.HasPrefix(pathName, "data:image/"),
-		strings.HasPrefix(pathName, "WIFI:"):
+		strings.HasPrefix(pathName, "WIFI:"),
+		strings.HasPrefix(pathName, "qr:"):
 		return []target{{pathName, true}}, nil
 	}
 	return nil, fmt.Errorf("open %q: not supported", pathName)
 }
 
-// targets are either paths that exist on disk, or a url.
+// targets are either paths that exist on disk, a url, or stdin ("-").
 type target struct {
 	path  string
 	isURL bool
@@ -194,45 +369,135 @@ type target struct {
 
 // render renders the target v to w.
 func (args *Args) render(w io.Writer, v target) error {
-	if !args.Quiet {
-		fmt.Fprintln(w, v.path+":")
+	defer args.removeTemps()
+	return args.display(w, v, args.decodeTarget(v))
+}
+
+// decodeTarget decodes the target v, returning the items to display.
+func (args *Args) decodeTarget(v target) []item {
+	if len(args.pageRanges) != 0 {
+		return args.decodePages(v)
 	}
 	start := time.Now()
-	var img image.Image
-	var mime string
-	var err error
-	// render
-	switch {
-	case !v.isURL:
-		img, mime, err = args.renderFile(v.path)
-	case strings.HasPrefix(v.path, "data:image/"):
-		img, mime, err = args.renderDataImage(v.path)
-	case v.isURL, strings.HasPrefix(v.path, "WIFI:"):
-		img, mime, err = args.renderWifiQR(v.path)
-	default:
-		return errors.New("unknown url scheme")
+	img, mime, err := args.decode(v)
+	args.logger("decode: %v", time.Since(start))
+	return []item{{
+		header: v.path,
+		decode: func() (image.Image, string, error) {
+			return img, mime, err
+		},
+	}}
+}
+
+// display displays the items of the target v to w, each with its header.
+// Returns the error when there is only a single item.
+func (args *Args) display(w io.Writer, v target, items []item) error {
+	for _, it := range items {
+		if args.ctx.Err() != nil {
+			break
+		}
+		if !args.Quiet {
+			fmt.Fprintln(w, it.header+":")
+		}
+		img, mime, err := it.decode()
+		if err == nil {
+			err = args.show(w, img, mime)
+		}
+		switch {
+		case err != nil && len(items) == 1:
+			return err
+		case err != nil:
+			fmt.Fprintf(w, "error: render %q: %v\n\n", v.path, err)
+		}
 	}
+	return nil
+}
+
+// item is an image to display, with its header and its page number, when a
+// page was selected.
+type item struct {
+	header string
+	page   int
+	decode func() (image.Image, string, error)
+}
+
+// show displays the decoded image to w.
+func (args *Args) show(w io.Writer, img image.Image, mime string) error {
+	// play animation
+	if a, ok := isAnimated(img); ok {
+		return args.play(w, a, mime)
+	}
+	// play video
+	if v, ok := isVideo(img); ok {
+		return args.playVideo(w, v)
+	}
+	img, h, err := args.prepare(img, mime)
 	if err != nil {
 		return err
 	}
-	// add background
-	img = args.addBackground(mime, img)
-	now := time.Now()
-	if err = rasterm.Encode(w, img); err != nil {
+	start := time.Now()
+	if err := args.encode(w, img); err != nil {
 		return err
 	}
-	args.logger("encode out: %v", time.Since(now))
-	args.logger("total: %v", time.Since(start))
+	args.logger("encode out: %v", time.Since(start))
+	if h != nil {
+		h.write(w)
+	}
 	return nil
 }
 
-// renderFile renders the file.
-func (args *Args) renderFile(pathName string) (image.Image, string, error) {
+// prepare transforms the image, adds the background, composes the histogram
+// when enabled, and scales the image to fit, returning the image and the
+// histogram.
+func (args *Args) prepare(img image.Image, mime string) (image.Image, *histogram, error) {
+	img, err := args.transform(img)
+	if err != nil {
+		return nil, nil, err
+	}
+	var h *histogram
+	if args.Histogram {
+		start := time.Now()
+		h = newHistogram(img)
+		args.logger("histogram: %v", time.Since(start))
+	}
+	img = args.addBackground(mime, img)
+	if h != nil {
+		img = h.compose(img)
+	}
+	return args.scale(img), h, nil
+}
+
+// decode decodes the target v, returning the image and its mime type.
+func (args *Args) decode(v target) (image.Image, string, error) {
+	args.resetConfig()
+	args.pages, args.file, args.decoderName, args.encrypted, args.converted = 0, "", "", false, nil
+	switch {
+	case !v.isURL && v.path == "-":
+		return args.renderStdin()
+	case !v.isURL:
+		return args.renderFile(v.path, "")
+	case strings.HasPrefix(v.path, "data:image/"):
+		return args.renderDataImage(v.path)
+	case urlRE.MatchString(v.path):
+		return args.renderURL(v.path)
+	case strings.HasPrefix(v.path, "qr:"):
+		return args.renderQR(strings.TrimPrefix(v.path, "qr:"))
+	case strings.HasPrefix(v.path, "WIFI:"), strings.HasPrefix(strings.ToLower(v.path), "wifi://"):
+		return args.renderQR(v.path)
+	}
+	return nil, "", errors.New("unknown url scheme")
+}
+
+// renderFile renders the file. When mime is empty, the mime type is detected
+// from the file's content.
+func (args *Args) renderFile(pathName, mime string) (image.Image, string, error) {
 	f, err := os.OpenFile(pathName, os.O_RDONLY, 0)
 	if err != nil {
 		return nil, "", err
 	}
-	mime := args.ForceMime
+	if args.ForceMime != "" {
+		mime = args.ForceMime
+	}
 	if mime == "" {
 		// determine mime
 		if mime, err = mimeDetect(f); err != nil {
@@ -241,7 +506,21 @@ func (args *Args) renderFile(pathName string) (image.Image, string, error) {
 		}
 	}
 	args.logger("mime: %s", mime)
-	g, notStream, err := args.decoder(mime, fileExt(pathName))
+	if err := args.applyConfig(mime, fileExt(pathName)); err != nil {
+		defer f.Close()
+		return nil, mime, err
+	}
+	args.file = pathName
+	name, g, notStream, err := args.decoder(mime, fileExt(pathName))
+	if err != nil {
+		defer f.Close()
+		return nil, mime, err
+	}
+	args.decoderName = name
+	key := args.cacheKey(pathName, mime)
+	if img, ok := args.cacheGet(key); ok {
+		return img, mime, f.Close()
+	}
 	if notStream {
 		if err := f.Close(); err != nil {
 			return nil, "", fmt.Errorf("file close: %w", err)
@@ -258,19 +537,31 @@ func (args *Args) renderFile(pathName string) (image.Image, string, error) {
 		if !notStream {
 			defer f.Close()
 		}
-		return nil, "", err
+		return nil, mime, err
 	}
 	if !notStream {
 		if err := f.Close(); err != nil {
 			return nil, "", fmt.Errorf("file close: %w", err)
 		}
 	}
+	args.cachePut(key, img)
 	return img, mime, nil
 }
 
-// renderWifiQR renders a WIFI: URL as a QR code.
-func (args *Args) renderWifiQR(urlstr string) (image.Image, string, error) {
-	q, err := qrcode.New(urlstr, qrcode.Medium)
+// renderStdin renders data read from stdin.
+func (args *Args) renderStdin() (image.Image, string, error) {
+	pathName, err := spool(os.Stdin, "")
+	if err != nil {
+		return nil, "", fmt.Errorf("stdin: %w", err)
+	}
+	args.temps = append(args.temps, pathName)
+	args.logger("stdin spooled: %s", pathName)
+	return args.renderFile(pathName, "")
+}
+
+// renderQR renders the text (ie, a WIFI: URL) as a QR code.
+func (args *Args) renderQR(text string) (image.Image, string, error) {
+	q, err := qrcode.New(text, qrcode.Medium)
 	if err != nil {
 		return nil, "", err
 	}
@@ -309,7 +600,7 @@ func (args *Args) renderDataImage(urlstr string) (image.Image, string, error) {
 	if err != nil {
 		return nil, "", fmt.Errorf("malformed data: %w", err)
 	}
-	g, _, err := args.decoder(strings.TrimSuffix(mime, "+xml"), "")
+	_, g, _, err := args.decoder(strings.TrimSuffix(mime, "+xml"), "")
 	if err != nil {
 		return nil, "", fmt.Errorf("data mime type %q: not supported", mime)
 	}
@@ -317,57 +608,95 @@ func (args *Args) renderDataImage(urlstr string) (image.Image, string, error) {
 	return img, mime, err
 }
 
-// decoder returns the decoder func to use with the mime and extension type.
-func (args *Args) decoder(mime, ext string) (func(string, string, io.ReadCloser) (image.Image, error), bool, error) {
+// decodeFunc is a decoder func.
+type decodeFunc func(string, string, io.ReadCloser) (image.Image, error)
+
+// decoder returns the name and decoder func to use with the mime and extension
+// type. Configured plugins take precedence over the decoders for the mime
+// type.
+func (args *Args) decoder(mime, ext string) (string, decodeFunc, bool, error) {
+	if p := args.plugin(mime, ext); p != nil {
+		return "plugin", args.decodePlugin, !p.stdin(), nil
+	}
+	return args.typeDecoder(mime, ext)
+}
+
+// typeDecoder returns the name and decoder func to use with the mime and
+// extension type.
+func (args *Args) typeDecoder(mime, ext string) (string, decodeFunc, bool, error) {
 	switch {
 	case mime == "image/svg":
-		return args.decodeResvg, false, nil
+		return "resvg", args.decodeResvg, false, nil
 	case isBuiltin(mime): // builtin
-		return args.decodeBuiltin, false, nil
+		return "builtin", args.decodeBuiltin, false, nil
 	case isLibreOffice(mime, ext): // soffice
-		return args.decodeLibreOffice, true, nil
+		return "libreoffice", args.decodeLibreOffice, true, nil
 	case isPdf(mime):
-		return args.decodeVipsPdf, false, nil
+		return "vipspdf", args.decodeVipsPdf, false, nil
 	case isVips(mime): // use vips
-		return args.decodeVips, false, nil
+		return "vips", args.decodeVips, false, nil
 	case isFitz(mime, ext):
-		return args.decodeFitz, false, nil
+		return "fitz", args.decodeFitz, false, nil
 	case isMermaid(mime, ext):
-		return args.decodeMermaid, true, nil
+		return "mermaid", args.decodeMermaid, true, nil
 	case mime == "text/plain":
-		return args.decodeMarkdown, false, nil
+		return "markdown", args.decodeMarkdown, false, nil
 	case isFont(mime, ext):
-		return args.decodeFont, false, nil
+		return "font", args.decodeFont, false, nil
 	case strings.HasPrefix(mime, "video/"):
-		return args.decodeFfmpeg, true, nil
+		return "ffmpeg", args.decodeFfmpeg, true, nil
 	case strings.HasPrefix(mime, "audio/"):
-		return args.decodeTag, false, nil
+		return "tag", args.decodeTag, false, nil
 	case isComicArchive(mime, ext):
-		return args.decodeComicArchive, false, nil
+		return "comicarchive", args.decodeComicArchive, false, nil
 	case isWindowsPE(mime, ext):
-		return args.decodeWindowsPE, false, nil
+		return "windowspe", args.decodeWindowsPE, false, nil
 	}
-	return nil, false, fmt.Errorf("mime type %q: not supported", mime)
+	return "", nil, false, fmt.Errorf("mime type %q: not supported", mime)
 }
 
 // decodeBuiltin decodes the image from the reader.
 func (args *Args) decodeBuiltin(pathName, mime string, r io.ReadCloser) (image.Image, error) {
-	img, _, err := image.Decode(r)
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var img image.Image
+	if isAnimation(mime) {
+		img, err = args.decodeAnimation(mime, buf)
+	} else {
+		img, _, err = image.Decode(bytes.NewReader(buf))
+	}
 	switch _, ok := errors.AsType[bmp.UnsupportedError](err); {
 	case err != nil && ok:
-		return args.decodeVips(pathName, mime, r)
+		return args.decodeVips(pathName, mime, io.NopCloser(bytes.NewReader(buf)))
 	case err != nil:
 		return nil, err
 	}
+	img = args.autorotate(img, buf)
 	b := img.Bounds()
 	args.logger("dimensions: %dx%d", b.Dx(), b.Dy())
-	return img, err
+	return img, nil
 }
 
 // decodeResvg decodes the svg from the reader.
 func (args *Args) decodeResvg(_, _ string, r io.ReadCloser) (image.Image, error) {
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
 	start := time.Now()
-	img, err := resvg.Decode(r)
+	// set background color and scaling
+	opts := []resvg.Option{resvg.WithBackground(args.Bg)}
+	if args.Width != 0 || args.Height != 0 {
+		opts = append(
+			opts,
+			resvg.WithScaleMode(resvg.ScaleBestFit),
+			resvg.WithWidth(max(int(args.Width), int(args.MinWidth))),
+			resvg.WithHeight(max(int(args.Height), int(args.MinHeight))),
+		)
+	}
+	img, err := resvg.Render(buf, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -407,8 +736,7 @@ func (args *Args) decodeVips(pathName, mime string, r io.ReadCloser) (image.Imag
 	vipsOnce.Do(vipsInit(args.logger, args.Verbose, int(args.VipsConcurrency)))
 	start := time.Now()
 	opts := &vips.LoadOptions{
-		N: 1,
-		// Autorotate:  true,
+		N:           1,
 		FailOnError: true,
 		Unlimited:   mime != "image/jxl" && !strings.HasSuffix(mime, "/pdf"),
 		Memory:      true,
@@ -418,8 +746,8 @@ func (args *Args) decodeVips(pathName, mime string, r io.ReadCloser) (image.Imag
 		if err != nil {
 			return nil, fmt.Errorf("vips load: %w", err)
 		}
-		if page := int(args.Page - 1); 0 <= page && page < v.Pages() {
-			opts.Page = page
+		if opts.Page, err = args.pageIndex(v.Pages()); err != nil {
+			return nil, err
 		}
 	}
 	v, err := vips.NewImageFromSource(vips.NewSource(r), opts)
@@ -427,6 +755,14 @@ func (args *Args) decodeVips(pathName, mime string, r io.ReadCloser) (image.Imag
 		return nil, fmt.Errorf("vips load: %w", err)
 	}
 	args.logger("vips load: %v", time.Since(start))
+	args.pages = v.Pages()
+	if o := v.Orientation(); o > 1 && !args.NoAutorotate {
+		start = time.Now()
+		if err := v.Autorot(nil); err != nil {
+			return nil, fmt.Errorf("vips autorotate: %w", err)
+		}
+		args.logger("vips exif orientation %d: %v", o, time.Since(start))
+	}
 	return args.vipsExport(v)
 }
 
@@ -441,12 +777,7 @@ func (args *Args) decodeVipsPdf(pathName, mime string, r io.ReadCloser) (image.I
 	var i int
 	for ; i < 3; i++ {
 		start := time.Now()
-		args.logger("vips load: %v", time.Since(start))
-		opts := &vips.PdfloadSourceOptions{
-			FailOn:   vips.FailOnError,
-			Memory:   true,
-			Password: string(pass),
-		}
+		opts := args.pdfloadOptions(pass)
 		if args.Page != 0 {
 			var vv *vips.Image
 			switch vv, err = vips.NewPdfloadSource(vips.NewSource(r), opts); {
@@ -454,8 +785,8 @@ func (args *Args) decodeVipsPdf(pathName, mime string, r io.ReadCloser) (image.I
 			case err != nil:
 				break
 			default:
-				if page := int(args.Page - 1); 0 <= page && page < vv.Pages() {
-					opts.Page = page
+				if opts.Page, err = args.pageIndex(vv.Pages()); err != nil {
+					return nil, err
 				}
 			}
 		}
@@ -464,10 +795,13 @@ func (args *Args) decodeVipsPdf(pathName, mime string, r io.ReadCloser) (image.I
 			args.logger("vips load: %v", time.Since(start))
 			break
 		}
+		args.encrypted = true
 		// collect password
+		promptMu.Lock()
 		_, _ = fmt.Fprint(os.Stdout, "Password: ")
 		pass, err = term.ReadPassword(int(os.Stdin.Fd()))
 		_, _ = fmt.Fprintln(os.Stdout)
+		promptMu.Unlock()
 		if err != nil {
 			break
 		}
@@ -478,9 +812,24 @@ func (args *Args) decodeVipsPdf(pathName, mime string, r io.ReadCloser) (image.I
 	case 3 <= i:
 		return nil, fmt.Errorf("vips load: invalid password")
 	}
+	args.pages = v.Pages()
 	return args.vipsExport(v)
 }
 
+// pdfloadOptions returns the vips pdf load options for the password. The vips
+// default dpi is used when the dpi is not set.
+func (args *Args) pdfloadOptions(pass []byte) *vips.PdfloadSourceOptions {
+	opts := &vips.PdfloadSourceOptions{
+		FailOn:   vips.FailOnError,
+		Memory:   true,
+		Password: string(pass),
+	}
+	if args.DPI != 0 {
+		opts.Dpi = float64(args.DPI)
+	}
+	return opts
+}
+
 // decodeFitz decodes the image using the fitz (mupdf) package.
 func (args *Args) decodeFitz(pathName, _ string, r io.ReadCloser) (image.Image, error) {
 	start := time.Now()
@@ -492,10 +841,11 @@ func (args *Args) decodeFitz(pathName, _ string, r io.ReadCloser) (image.Image,
 	defer d.Close()
 	args.logger("fitz load: %v", time.Since(start))
 	args.logger("fitz pages: %d", d.NumPage())
+	args.pages = d.NumPage()
 	// page
-	page := 0
-	if p := int(args.Page); p != 0 && p <= d.NumPage() {
-		page = p - 1
+	page, err := args.pageIndex(d.NumPage())
+	if err != nil {
+		return nil, err
 	}
 	// render
 	var img *image.RGBA
@@ -514,19 +864,24 @@ func (args *Args) decodeFitz(pathName, _ string, r io.ReadCloser) (image.Image,
 
 // decodeFfmpeg decodes the image using the ffmpeg command.
 func (args *Args) decodeFfmpeg(pathName, _ string, _ io.ReadCloser) (image.Image, error) {
-	var err error
-	ffmpegOnce.Do(func() {
-		ffprobePath, _ = exec.LookPath("ffprobe")
-		ffmpegPath, err = exec.LookPath("ffmpeg")
-	})
-	switch {
-	case err != nil:
+	if err := ffmpegInit(); err != nil {
 		return nil, err
-	case ffmpegPath == "":
-		return nil, errors.New("ffmpeg not in path")
+	}
+	if args.Storyboard != 0 {
+		return args.ffmpegStoryboard(pathName)
 	}
 	tc := args.ffprobeTimecode(pathName)
 	args.logger("snapshot at %v", tc)
+	img, err := args.ffmpegFrame(pathName, tc)
+	if err != nil || !args.Play {
+		return img, err
+	}
+	return &video{Image: img, pathName: pathName}, nil
+}
+
+// ffmpegFrame extracts a single frame at the time code from the video using
+// the ffmpeg command.
+func (args *Args) ffmpegFrame(pathName, tc string) (image.Image, error) {
 	params := []string{
 		`-hide_banner`,
 		`-ss`, tc,
@@ -556,34 +911,33 @@ func (args *Args) decodeFfmpeg(pathName, _ string, _ io.ReadCloser) (image.Image
 	return png.Decode(&buf)
 }
 
+// ffmpegInit looks up the ffmpeg and ffprobe commands.
+func ffmpegInit() error {
+	var err error
+	ffmpegOnce.Do(func() {
+		ffprobePath, _ = exec.LookPath("ffprobe")
+		ffmpegPath, err = exec.LookPath("ffmpeg")
+	})
+	switch {
+	case err != nil:
+		return err
+	case ffmpegPath == "":
+		return errors.New("ffmpeg not in path")
+	}
+	return nil
+}
+
 func (args *Args) ffprobeTimecode(pathName string) string {
 	switch {
 	case ffprobePath == "":
 		return "00:00"
-	case args.TimeCode != 0:
-		return formatTimecode(args.TimeCode)
-	}
-	params := []string{
-		`-loglevel`, `quiet`,
-		`-show_format`,
-		pathName,
-	}
-	args.logger("ffprobe: executing %s %s", ffprobePath, strings.Join(params, " "))
-	cmd := exec.CommandContext(args.ctx, ffprobePath, params...)
-	buf, err := cmd.CombinedOutput()
-	if err != nil {
-		return "00:00"
-	}
-	m := durationRE.FindStringSubmatch(string(buf))
-	if m == nil {
-		return "00:00"
+	case args.TimeCode.Duration() != 0:
+		return formatTimecode(args.TimeCode.Duration())
 	}
-	f, err := strconv.ParseFloat(m[1], 64)
+	dur, err := args.ffprobeDuration(pathName)
 	if err != nil {
 		return "00:00"
 	}
-	dur := time.Duration(f * float64(time.Second))
-	args.logger("ffprobe duration: %v / %s", dur, formatTimecode(dur))
 	switch {
 	case dur >= 1*time.Hour:
 		return "10:00"
@@ -603,6 +957,36 @@ func (args *Args) ffprobeTimecode(pathName string) string {
 	return "00:00"
 }
 
+// ffprobeDuration returns the duration of the video using the ffprobe
+// command.
+func (args *Args) ffprobeDuration(pathName string) (time.Duration, error) {
+	if ffprobePath == "" {
+		return 0, errors.New("ffprobe not in path")
+	}
+	params := []string{
+		`-loglevel`, `quiet`,
+		`-show_format`,
+		pathName,
+	}
+	args.logger("ffprobe: executing %s %s", ffprobePath, strings.Join(params, " "))
+	cmd := exec.CommandContext(args.ctx, ffprobePath, params...)
+	buf, err := cmd.CombinedOutput()
+	if err != nil {
+		return 0, err
+	}
+	m := durationRE.FindStringSubmatch(string(buf))
+	if m == nil {
+		return 0, errors.New("ffprobe: no duration")
+	}
+	f, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe: invalid duration: %w", err)
+	}
+	dur := time.Duration(f * float64(time.Second))
+	args.logger("ffprobe duration: %v / %s", dur, formatTimecode(dur))
+	return dur, nil
+}
+
 var durationRE = regexp.MustCompile(`(?m)^duration=(.*)$`)
 
 // formatTimecode formats a duration in ffmpeg's timecode format.
@@ -645,7 +1029,10 @@ func (args *Args) decodeLibreOffice(pathName, _ string, _ io.ReadCloser) (image.
 		sofficePath,
 		params...,
 	)
+	// soffice does not convert concurrently using the same user profile
+	sofficeMu.Lock()
 	buf, err := cmd.CombinedOutput()
+	sofficeMu.Unlock()
 	if err != nil {
 		if len(buf) > 100 {
 			buf = buf[:100]
@@ -662,6 +1049,8 @@ func (args *Args) decodeLibreOffice(pathName, _ string, _ io.ReadCloser) (image.
 	if err != nil {
 		return nil, err
 	}
+	// keep the pdf to decode the other pages
+	args.temps = append(args.temps, pdfName, tmpDir)
 	img, err := args.decodeVipsPdf(pdfName, "application/pdf", f)
 	if err != nil {
 		defer f.Close()
@@ -670,10 +1059,7 @@ func (args *Args) decodeLibreOffice(pathName, _ string, _ io.ReadCloser) (image.
 	if err := f.Close(); err != nil {
 		return nil, err
 	}
-	args.logger("removing: %s", tmpDir)
-	if err := os.RemoveAll(tmpDir); err != nil {
-		return nil, err
-	}
+	args.convert(pdfName, "application/pdf", args.decodeVipsPdf)
 	return img, nil
 }
 
@@ -793,9 +1179,13 @@ func (args *Args) decodeComicArchive(pathName, mime string, r io.ReadCloser) (im
 	if err != nil {
 		return nil, err
 	}
-	i := 0
-	if page := int(args.Page - 1); 0 <= page && page < len(files) {
-		i = page
+	if len(files) == 0 {
+		return nil, errors.New("no images found")
+	}
+	args.pages = len(files)
+	i, err := args.pageIndex(len(files))
+	if err != nil {
+		return nil, err
 	}
 	f, err := fsys.Open(files[i])
 	if err != nil {
@@ -849,11 +1239,10 @@ func (args *Args) decodeWindowsPE(pathName, mime string, r io.ReadCloser) (image
 	if len(icons) == 0 {
 		return nil, fmt.Errorf("no icons found")
 	}
-	page := 0
-	if args.Page != 0 {
-		if p := int(args.Page - 1); 0 <= p && p < len(icons) {
-			page = p
-		}
+	args.pages = len(icons)
+	page, err := args.pageIndex(len(icons))
+	if err != nil {
+		return nil, err
 	}
 	return icons[page], nil
 }
@@ -909,11 +1298,21 @@ func (args *Args) decodeMarkdown(pathName, _ string, r io.ReadCloser) (image.Ima
 	}
 	args.logger("md convert: %v", time.Since(start))
 	start = time.Now()
-	pdf, err := args.decodeVipsPdf(pathName, "application/pdf", io.NopCloser(buf))
+	data := buf.Bytes()
+	pdf, err := args.decodeVipsPdf(pathName, "application/pdf", io.NopCloser(bytes.NewReader(data)))
 	if err != nil {
 		return nil, err
 	}
 	args.logger("md render: %v", time.Since(start))
+	// keep the pdf to decode the other pages
+	if args.pages > 1 {
+		pdfName, err := spool(bytes.NewReader(data), ".pdf")
+		if err != nil {
+			return nil, err
+		}
+		args.temps = append(args.temps, pdfName)
+		args.convert(pdfName, "application/pdf", args.decodeVipsPdf)
+	}
 	return pdf, nil
 }
 
@@ -978,15 +1377,11 @@ func (fs files) Open(urlstr string) (http.File, error) {
 		return nil, err
 	}
 	pathName := path.Base(u.Path)
-	req, err := http.NewRequestWithContext(fs.args.ctx, "GET", urlstr, nil)
+	// untrusted url, so do not send credentials
+	res, err := fs.args.get(urlstr, false)
 	if err != nil {
 		return nil, fmt.Errorf("md open: %w", err)
 	}
-	cl := &http.Client{}
-	res, err := cl.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("md open: do: %w", err)
-	}
 	defer res.Body.Close()
 	img, err := fs.args.decodeVips(pathName, "", res.Body)
 	if err != nil {
@@ -1099,6 +1494,40 @@ func (img *Image) At(x, y int) color.Color {
 	return nil
 }
 
+// duration is a duration option, as ox binds [time.Duration] options as
+// integers.
+type duration time.Duration
+
+func init() {
+	ox.RegisterTypeName("*main.duration", "*main.duration")
+	ox.RegisterTextType(func() (*duration, error) {
+		return new(duration), nil
+	})
+}
+
+// Duration returns the duration, or 0 when d is nil.
+func (d *duration) Duration() time.Duration {
+	if d == nil {
+		return 0
+	}
+	return time.Duration(*d)
+}
+
+// MarshalText satisfies the [encoding.TextMarshaler] interface.
+func (d *duration) MarshalText() ([]byte, error) {
+	return []byte(d.Duration().String()), nil
+}
+
+// UnmarshalText satisfies the [encoding.TextUnmarshaler] interface.
+func (d *duration) UnmarshalText(buf []byte) error {
+	v, err := time.ParseDuration(string(buf))
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
 // nopWriteCloser wraps a writer with a noop close method.
 type nopWriteCloser struct {
 	io.Writer
@@ -1109,6 +1538,50 @@ func (nopWriteCloser) Close() error {
 	return nil
 }
 
+// spool copies the reader to a temporary file, returning the file's path. When
+// ext is empty, the file extension is determined from the reader's content.
+func spool(r io.Reader, ext string) (string, error) {
+	br := bufio.NewReaderSize(r, 4096)
+	if ext == "" {
+		buf, err := br.Peek(3072)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+			return "", err
+		}
+		ext = mimetype.Detect(buf).Extension()
+	}
+	f, err := os.CreateTemp("", name+".*"+ext)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, br); err != nil {
+		defer os.Remove(f.Name())
+		defer f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		defer os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// removeTemps removes the temporary files.
+func (args *Args) removeTemps() {
+	for _, pathName := range args.temps {
+		args.logger("removing: %s", pathName)
+		if err := os.Remove(pathName); err != nil {
+			args.logger("unable to remove %s: %v", pathName, err)
+		}
+	}
+	args.temps = nil
+}
+
+// isRegularFile returns true when f is a regular file.
+func isRegularFile(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode().IsRegular()
+}
+
 // mimeDetect determines the mime type for the reader.
 func mimeDetect(r io.Reader) (string, error) {
 	mime, err := mimetype.DetectReader(r)
@@ -1123,16 +1596,17 @@ func mimeDetect(r io.Reader) (string, error) {
 }
 
 // isBuiltin returns true if the mime type is a supported, builtin Go image
-// type.
+// type. TIFF images are not builtin, as they are decoded by vips, which
+// supports multi-page files.
 func isBuiltin(typ string) bool {
 	switch typ {
 	case
 		"image/bmp",
 		"image/jpeg",
 		"image/png",
+		"image/apng",
 		"image/gif",
 		"image/webp",
-		"image/tiff",
 		"image/x-icon",
 		"image/x-icns":
 		return true
@@ -1142,6 +1616,16 @@ func isBuiltin(typ string) bool {
 	return strings.HasPrefix(typ, "image/x-portable-")
 }
 
+// isAnimation returns true if the mime type is a builtin type that can be
+// animated.
+func isAnimation(typ string) bool {
+	switch typ {
+	case "image/gif", "image/png", "image/apng", "image/webp":
+		return true
+	}
+	return false
+}
+
 // isPdf returns true if the mime type is a pdf.
 func isPdf(typ string) bool {
 	return typ == "application/pdf"
@@ -1263,6 +1747,11 @@ var (
 	mmdcOnce    sync.Once
 )
 
+var (
+	sofficeMu sync.Mutex
+	promptMu  sync.Mutex
+)
+
 var (
 	sofficePath string
 	ffprobePath string
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestFileArgs(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile("help", nil, 0o644); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	tests := []struct {
+		args []string
+		exp  []string
+	}{
+		{nil, nil},
+		{[]string{"a.png"}, []string{"a.png"}},
+		{[]string{"help"}, []string{"./help"}},
+		{[]string{"-v", "help", "a.png"}, []string{"-v", "./help", "a.png"}},
+		{[]string{"diff", "a.png", "b.png"}, []string{"diff", "a.png", "b.png"}},
+		{[]string{"--", "help"}, []string{"--", "help"}},
+	}
+	for i, test := range tests {
+		if args := fileArgs(test.args); !slices.Equal(args, test.exp) {
+			t.Errorf("test %d expected %q, got: %q", i, test.exp, args)
+		}
+	}
+}
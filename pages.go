@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// decodePages decodes the first page and page count of the target v,
+// returning an item with a page header for each selected page. Pages other
+// than the first are decoded when displayed.
+func (args *Args) decodePages(v target) []item {
+	page := args.Page
+	defer func() {
+		args.Page = page
+	}()
+	args.Page = 0
+	first, mime, err := args.decode(v)
+	if err != nil {
+		return []item{{header: v.path, decode: func() (image.Image, string, error) {
+			return nil, mime, err
+		}}}
+	}
+	n, file := max(args.pages, 1), args.file
+	pages, err := resolvePages(args.pageRanges, n)
+	if err != nil {
+		return []item{{header: v.path, decode: func() (image.Image, string, error) {
+			return nil, mime, err
+		}}}
+	}
+	var items []item
+	for _, p := range pages {
+		items = append(items, item{
+			header: fmt.Sprintf("%s [%d/%d]", v.path, p, n),
+			page:   p,
+			decode: func() (image.Image, string, error) {
+				if p == 1 {
+					return first, mime, nil
+				}
+				img, err := args.decodePage(first, mime, file, p)
+				if err != nil {
+					return nil, mime, fmt.Errorf("page %d: %w", p, err)
+				}
+				return img, mime, nil
+			},
+		})
+	}
+	return items
+}
+
+// decodePage decodes page p of the previously decoded file. The first
+// decoded image is used when the target is not a file. Pages of converted
+// files (ie, documents converted to pdf by soffice) are decoded from the
+// intermediate file, instead of converting the file again.
+func (args *Args) decodePage(first image.Image, mime, file string, p int) (image.Image, error) {
+	if file == "" {
+		return first, nil
+	}
+	page := args.Page
+	defer func() {
+		args.Page = page
+	}()
+	args.Page = uint(p)
+	if c := args.converted; c != nil {
+		args.logger("page %d: decoding converted %s", p, c.pathName)
+		f, err := os.Open(c.pathName)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return c.decode(c.pathName, c.mime, f)
+	}
+	img, _, err := args.renderFile(file, mime)
+	return img, err
+}
+
+// converted is an intermediate file converted from a multi-page file, and the
+// decoder for its pages.
+type converted struct {
+	pathName string
+	mime     string
+	decode   decodeFunc
+}
+
+// convert sets the intermediate file converted from the decoded file, when
+// the decoded file has multiple pages. The intermediate file should be
+// removed with the temporary files.
+func (args *Args) convert(pathName, mime string, decode decodeFunc) {
+	if args.pages > 1 {
+		args.converted = &converted{pathName: pathName, mime: mime, decode: decode}
+	}
+}
+
+// pageIndex returns the zero-based index of the selected page of a document
+// having n pages, or an error when the page is out of range.
+func (args *Args) pageIndex(n int) (int, error) {
+	switch {
+	case args.Page == 0:
+		return 0, nil
+	case int(args.Page) > n:
+		return 0, fmt.Errorf("page %d out of range (1-%d)", args.Page, n)
+	}
+	return int(args.Page) - 1, nil
+}
+
+// pageRange is an inclusive range of pages. A page of 0 is the last page.
+type pageRange struct {
+	start, end int
+}
+
+// parsePages parses a comma separated list of pages and page ranges, such as
+// "1-3,7,last". A range without an end continues to the last page.
+func parsePages(s string) ([]pageRange, error) {
+	var ranges []pageRange
+	for v := range strings.SplitSeq(s, ",") {
+		a, b, isRange := strings.Cut(strings.TrimSpace(v), "-")
+		if isRange && b == "" {
+			b = "last"
+		}
+		start, err := parsePage(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pages %q: %w", s, err)
+		}
+		end := start
+		if isRange {
+			if end, err = parsePage(b); err != nil {
+				return nil, fmt.Errorf("invalid pages %q: %w", s, err)
+			}
+		}
+		if start != 0 && end != 0 && end < start {
+			return nil, fmt.Errorf("invalid pages %q: range %s is reversed", s, v)
+		}
+		ranges = append(ranges, pageRange{start, end})
+	}
+	return ranges, nil
+}
+
+// parsePage parses a page number or "last", returning 0 for the last page.
+func parsePage(s string) (int, error) {
+	if strings.EqualFold(s, "last") {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(s)
+	switch {
+	case err != nil:
+		return 0, fmt.Errorf("invalid page %q", s)
+	case i < 1:
+		return 0, errors.New("pages start at 1")
+	}
+	return i, nil
+}
+
+// resolvePages resolves the page ranges for a document having n pages.
+func resolvePages(ranges []pageRange, n int) ([]int, error) {
+	var pages []int
+	for _, r := range ranges {
+		start, end := r.start, r.end
+		if start == 0 {
+			start = n
+		}
+		if end == 0 {
+			end = n
+		}
+		switch {
+		case start > n:
+			return nil, fmt.Errorf("page %d out of range (1-%d)", start, n)
+		case end > n:
+			return nil, fmt.Errorf("page %d out of range (1-%d)", end, n)
+		case end < start:
+			return nil, fmt.Errorf("page range %d-%d is reversed", start, end)
+		}
+		for p := start; p <= end; p++ {
+			pages = append(pages, p)
+		}
+	}
+	return pages, nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/gif"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParsePages(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp []pageRange
+		err bool
+	}{
+		{"1", []pageRange{{1, 1}}, false},
+		{"1-3,7", []pageRange{{1, 3}, {7, 7}}, false},
+		{" 2 , 4-5 ", []pageRange{{2, 2}, {4, 5}}, false},
+		{"last", []pageRange{{0, 0}}, false},
+		{"LAST", []pageRange{{0, 0}}, false},
+		{"3-", []pageRange{{3, 0}}, false},
+		{"3-last", []pageRange{{3, 0}}, false},
+		{"last-last", []pageRange{{0, 0}}, false},
+		{"5-5", []pageRange{{5, 5}}, false},
+		{"", nil, true},
+		{"0", nil, true},
+		{"-1", nil, true},
+		{"a", nil, true},
+		{"1,,2", nil, true},
+		{"3-1", nil, true},
+		{"1-2-3", nil, true},
+		{"1.5", nil, true},
+	}
+	for i, test := range tests {
+		ranges, err := parsePages(test.s)
+		switch {
+		case test.err && err == nil:
+			t.Errorf("test %d %q expected error, got: %v", i, test.s, ranges)
+		case !test.err && err != nil:
+			t.Errorf("test %d %q expected no error, got: %v", i, test.s, err)
+		case !slices.Equal(ranges, test.exp):
+			t.Errorf("test %d %q expected %v, got: %v", i, test.s, test.exp, ranges)
+		}
+	}
+}
+
+func TestResolvePages(t *testing.T) {
+	tests := []struct {
+		s   string
+		n   int
+		exp []int
+		err bool
+	}{
+		{"1", 1, []int{1}, false},
+		{"1-3,7", 10, []int{1, 2, 3, 7}, false},
+		{"last", 5, []int{5}, false},
+		{"3-", 5, []int{3, 4, 5}, false},
+		{"3-last", 3, []int{3}, false},
+		{"2,2", 3, []int{2, 2}, false},
+		{"4-last", 3, nil, true},
+		{"1-4", 3, nil, true},
+		{"4", 3, nil, true},
+		{"1", 0, nil, true},
+	}
+	for i, test := range tests {
+		ranges, err := parsePages(test.s)
+		if err != nil {
+			t.Fatalf("test %d %q expected no error, got: %v", i, test.s, err)
+		}
+		pages, err := resolvePages(ranges, test.n)
+		switch {
+		case test.err && err == nil:
+			t.Errorf("test %d %q (%d) expected error, got: %v", i, test.s, test.n, pages)
+		case !test.err && err != nil:
+			t.Errorf("test %d %q (%d) expected no error, got: %v", i, test.s, test.n, err)
+		case !slices.Equal(pages, test.exp):
+			t.Errorf("test %d %q (%d) expected %v, got: %v", i, test.s, test.n, test.exp, pages)
+		}
+	}
+}
+
+func TestDecodePagesConverted(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skipf("sh not available: %v", err)
+	}
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", dir)
+	// a three frame gif, converted by a plugin counting its runs
+	g := &gif.GIF{}
+	for _, c := range []color.Color{color.White, color.Black, color.Gray{0x80}} {
+		img := image.NewPaletted(image.Rect(0, 0, 2, 2), color.Palette{color.White, color.Black, color.Gray{0x80}})
+		for i := range img.Pix {
+			img.Pix[i] = uint8(img.Palette.Index(c))
+		}
+		g.Image, g.Delay = append(g.Image, img), append(g.Delay, 1)
+	}
+	buf := new(bytes.Buffer)
+	if err := gif.EncodeAll(buf, g); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	pathName, count := filepath.Join(dir, "a.frames"), filepath.Join(dir, "count")
+	if err := os.WriteFile(pathName, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	p, err := newPlugin("frames", map[string][]string{
+		"ext":     {"frames"},
+		"command": {"sh", "-c", `echo >> "$1"; cat "$2"`, "sh", count, "{path}"},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	tests := []struct {
+		pages string
+		exp   int
+	}{
+		{"1", 1},
+		{"2-3", 1},
+		{"1-last,2", 1},
+	}
+	for i, test := range tests {
+		if err := os.Remove(count); err != nil && !os.IsNotExist(err) {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		ranges, err := parsePages(test.pages)
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		args := &Args{
+			ctx:        context.Background(),
+			logger:     t.Logf,
+			NoCache:    true,
+			config:     &config{plugins: []*plugin{p}},
+			pageRanges: ranges,
+		}
+		for _, it := range args.decodePages(target{path: pathName}) {
+			if _, _, err := it.decode(); err != nil {
+				t.Errorf("test %d %s expected no error, got: %v", i, it.header, err)
+			}
+		}
+		args.removeTemps()
+		buf, err := os.ReadFile(count)
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if n := strings.Count(string(buf), "\n"); n != test.exp {
+			t.Errorf("test %d expected %d plugin runs, got: %d", i, test.exp, n)
+		}
+	}
+}
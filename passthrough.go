@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"io"
+	"os"
+	"slices"
+	"sync"
+)
+
+// multiplexer returns the terminal multiplexer iv is running in, either
+// "tmux" or "screen", or empty when not running in a multiplexer.
+func multiplexer() string {
+	switch {
+	case os.Getenv("TMUX") != "":
+		return "tmux"
+	case os.Getenv("STY") != "":
+		return "screen"
+	}
+	return ""
+}
+
+// passthrough wraps the graphics escape sequences in buf in the
+// multiplexer's passthrough envelope, so that they are passed to the outer
+// terminal. Other output, such as text and newlines, is not wrapped. Sixel
+// output is not wrapped when tmux supports sixel.
+func (args *Args) passthrough(buf []byte) []byte {
+	if args.mux == "" {
+		return buf
+	}
+	out := new(bytes.Buffer)
+	for len(buf) != 0 {
+		i := graphicsStart(buf)
+		if i == -1 {
+			out.Write(buf)
+			break
+		}
+		out.Write(buf[:i])
+		buf = buf[i:]
+		n := graphicsEnd(buf)
+		seq := buf[:n]
+		buf = buf[n:]
+		if args.mux == "tmux" && seq[1] == 'P' && tmuxSixel() {
+			out.Write(seq)
+			continue
+		}
+		args.wrap(out, seq)
+	}
+	return out.Bytes()
+}
+
+// wrap writes the escape sequence to w, wrapped in the multiplexer's
+// passthrough envelope. Screen limits the length of device control strings,
+// so the sequence is split into multiple envelopes. The sequence's string
+// terminator would end screen's envelope, so its escape and backslash are
+// split into separate envelopes.
+func (args *Args) wrap(w *bytes.Buffer, seq []byte) {
+	switch args.mux {
+	case "tmux":
+		w.WriteString("\x1bPtmux;")
+		w.Write(bytes.ReplaceAll(seq, []byte{0x1b}, []byte{0x1b, 0x1b}))
+		w.WriteString("\x1b\\")
+	case "screen":
+		st := bytes.HasSuffix(seq, []byte("\x1b\\"))
+		if st {
+			seq = seq[:len(seq)-1]
+		}
+		for chunk := range slices.Chunk(seq, screenChunkSize) {
+			w.WriteString("\x1bP")
+			w.Write(chunk)
+			w.WriteString("\x1b\\")
+		}
+		if st {
+			w.WriteString("\x1bP\\\x1b\\")
+		}
+	default:
+		w.Write(seq)
+	}
+}
+
+// graphicsStart returns the index of the first graphics escape sequence in
+// buf (sixel, kitty, or iterm), or -1.
+func graphicsStart(buf []byte) int {
+	for i := 0; i+1 < len(buf); i++ {
+		if buf[i] != 0x1b {
+			continue
+		}
+		switch buf[i+1] {
+		case 'P', '_':
+			return i
+		case ']':
+			if bytes.HasPrefix(buf[i+2:], []byte("1337;")) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// graphicsEnd returns the length of the escape sequence at the start of buf,
+// ending with the string terminator (or bel, for iterm).
+func graphicsEnd(buf []byte) int {
+	for i := 2; i < len(buf); i++ {
+		switch {
+		case buf[i] == 0x07 && buf[1] == ']':
+			return i + 1
+		case buf[i] == 0x1b && i+1 < len(buf) && buf[i+1] == '\\':
+			return i + 2
+		}
+	}
+	return len(buf)
+}
+
+// tmuxSixel returns true when tmux reports sixel support in its primary
+// device attributes.
+var tmuxSixel = sync.OnceValue(func() bool {
+	_, attrs := queryAttributes()
+	return sixelAttribute(attrs)
+})
+
+// encodeMux encodes the image to w when running in a terminal multiplexer,
+// wrapping the graphics in passthrough envelopes.
+func (args *Args) encodeMux(w io.Writer, img image.Image) error {
+	buf := new(bytes.Buffer)
+	if err := args.protocol.Encode(buf, img); err != nil {
+		return err
+	}
+	_, err := w.Write(args.passthrough(buf.Bytes()))
+	return err
+}
+
+// screenChunkSize is the maximum length of a screen passthrough envelope's
+// contents.
+const screenChunkSize = 760
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWrap(t *testing.T) {
+	long := strings.Repeat("a", screenChunkSize-3)
+	tests := []struct {
+		mux string
+		seq string
+		exp string
+	}{
+		{"", "\x1b_Ga=T;AAAA\x1b\\", "\x1b_Ga=T;AAAA\x1b\\"},
+		{"tmux", "\x1b_Ga=T;AAAA\x1b\\", "\x1bPtmux;\x1b\x1b_Ga=T;AAAA\x1b\x1b\\\x1b\\"},
+		{"tmux", "\x1b]1337;File=:AAAA\x07", "\x1bPtmux;\x1b\x1b]1337;File=:AAAA\x07\x1b\\"},
+		{"screen", "\x1b_Ga=T;AAAA\x1b\\", "\x1bP\x1b_Ga=T;AAAA\x1b\x1b\\\x1bP\\\x1b\\"},
+		{"screen", "\x1b]1337;File=:AAAA\x07", "\x1bP\x1b]1337;File=:AAAA\x07\x1b\\"},
+		{
+			"screen",
+			"\x1bPq" + long + "\x1b\\",
+			"\x1bP\x1bPq" + long + "\x1b\\" + "\x1bP\x1b\x1b\\" + "\x1bP\\\x1b\\",
+		},
+		{
+			"screen",
+			"\x1bPq" + long + "b\x1b\\",
+			"\x1bP\x1bPq" + long + "\x1b\\" + "\x1bPb\x1b\x1b\\" + "\x1bP\\\x1b\\",
+		},
+	}
+	for i, test := range tests {
+		args := &Args{mux: test.mux}
+		buf := new(bytes.Buffer)
+		args.wrap(buf, []byte(test.seq))
+		if s := buf.String(); s != test.exp {
+			t.Errorf("test %d expected %q, got: %q", i, test.exp, s)
+		}
+	}
+}
+
+func TestGraphicsEnd(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp int
+	}{
+		{"\x1bPq#0\x1b\\rest", 7},
+		{"\x1b_Ga=T\x1b\\\x1b_G", 8},
+		{"\x1b]1337;File=:AA\x07rest", 16},
+		{"\x1b_Ga=T", 6},
+	}
+	for i, test := range tests {
+		if n := graphicsEnd([]byte(test.s)); n != test.exp {
+			t.Errorf("test %d expected %d, got: %d", i, test.exp, n)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// plugin is an external decoder command for files of the plugin's mime types
+// and extensions. The command writes an image, svg, or pdf to stdout, which
+// is then decoded with the matching decoder.
+type plugin struct {
+	name    string
+	mime    []string
+	ext     []string
+	command []string
+}
+
+// newPlugin creates a plugin from its configuration table.
+func newPlugin(name string, opts map[string][]string) (*plugin, error) {
+	p := &plugin{name: name}
+	for _, key := range slices.Sorted(maps.Keys(opts)) {
+		switch v := opts[key]; key {
+		case "mime":
+			p.mime = v
+		case "ext":
+			for _, ext := range v {
+				p.ext = append(p.ext, strings.ToLower(strings.TrimPrefix(ext, ".")))
+			}
+		case "command":
+			// a single string is split on whitespace
+			if len(v) == 1 {
+				v = strings.Fields(v[0])
+			}
+			p.command = v
+		default:
+			return nil, fmt.Errorf("plugin %s: unknown key %q", name, key)
+		}
+	}
+	switch {
+	case len(p.command) == 0:
+		return nil, fmt.Errorf("plugin %s: missing command", name)
+	case len(p.mime) == 0 && len(p.ext) == 0:
+		return nil, fmt.Errorf("plugin %s: missing mime or ext", name)
+	}
+	return p, nil
+}
+
+// match returns true when the plugin decodes the mime type or extension.
+func (p *plugin) match(mime, ext string) bool {
+	for _, pattern := range p.mime {
+		if ok, _ := path.Match(pattern, mime); ok {
+			return true
+		}
+	}
+	return ext != "" && slices.Contains(p.ext, ext)
+}
+
+// stdin returns true when the file is passed to the command on stdin, which
+// is when the command does not contain the {path} placeholder.
+func (p *plugin) stdin() bool {
+	return !slices.ContainsFunc(p.command, func(s string) bool {
+		return strings.Contains(s, "{path}")
+	})
+}
+
+// params returns the command's parameters, replacing the {path}, {mime},
+// {ext}, and {dpi} placeholders.
+func (p *plugin) params(pathName, mime, ext string, dpi uint) []string {
+	replacer := strings.NewReplacer(
+		"{path}", pathName,
+		"{mime}", mime,
+		"{ext}", ext,
+		"{dpi}", strconv.FormatUint(uint64(dpi), 10),
+	)
+	params := make([]string, len(p.command))
+	for i, s := range p.command {
+		params[i] = replacer.Replace(s)
+	}
+	return params
+}
+
+// plugin returns the plugin for the mime type and extension, or nil.
+func (args *Args) plugin(mime, ext string) *plugin {
+	if args.config == nil {
+		return nil
+	}
+	for _, p := range args.config.plugins {
+		if p.match(mime, ext) {
+			return p
+		}
+	}
+	return nil
+}
+
+// decodePlugin decodes the file using the plugin's command, decoding its
+// output with the decoder for the output's mime type.
+func (args *Args) decodePlugin(pathName, mime string, r io.ReadCloser) (image.Image, error) {
+	ext := fileExt(pathName)
+	p := args.plugin(mime, ext)
+	if p == nil {
+		return nil, fmt.Errorf("mime type %q: no plugin", mime)
+	}
+	params := p.params(pathName, mime, ext, args.DPI)
+	args.logger("executing plugin %s: %s", p.name, strings.Join(params, " "))
+	start := time.Now()
+	cmd := exec.CommandContext(args.ctx, params[0], params[1:]...)
+	if p.stdin() {
+		cmd.Stdin = r
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	for s := range strings.SplitSeq(strings.TrimSpace(stderr.String()), "\n") {
+		if s != "" {
+			args.logger("plugin %s: %s", p.name, s)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", p.name, err)
+	}
+	args.logger("plugin %s render: %v", p.name, time.Since(start))
+	if stdout.Len() == 0 {
+		return nil, fmt.Errorf("plugin %s: no output", p.name)
+	}
+	// decode output
+	typ, err := mimeDetect(bytes.NewReader(stdout.Bytes()))
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: mime read: %w", p.name, err)
+	}
+	args.logger("plugin %s output mime: %s", p.name, typ)
+	_, g, _, err := args.typeDecoder(typ, "")
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", p.name, err)
+	}
+	outName, err := spool(&stdout, "")
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", p.name, err)
+	}
+	args.temps = append(args.temps, outName)
+	f, err := os.Open(outName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := g(outName, typ, f)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", p.name, err)
+	}
+	args.convert(outName, typ, g)
+	return img, nil
+}
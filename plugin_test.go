@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestPluginMatch(t *testing.T) {
+	p := &plugin{mime: []string{"application/x-foo", "image/x-bar*"}, ext: []string{"foo"}}
+	tests := []struct {
+		mime string
+		ext  string
+		exp  bool
+	}{
+		{"application/x-foo", "", true},
+		{"image/x-bar", "", true},
+		{"image/x-barbaz", "bin", true},
+		{"application/octet-stream", "foo", true},
+		{"application/octet-stream", "FOO", false},
+		{"application/x-foobar", "", false},
+		{"", "", false},
+	}
+	for i, test := range tests {
+		if ok := p.match(test.mime, test.ext); ok != test.exp {
+			t.Errorf("test %d %q %q expected %t, got: %t", i, test.mime, test.ext, test.exp, ok)
+		}
+	}
+}
+
+func TestPluginParams(t *testing.T) {
+	tests := []struct {
+		command []string
+		exp     []string
+		stdin   bool
+	}{
+		{[]string{"foo2png", "{path}"}, []string{"foo2png", "/a b/c.foo"}, false},
+		{[]string{"foo2png", "--in={path}", "--dpi", "{dpi}"}, []string{"foo2png", "--in=/a b/c.foo", "--dpi", "150"}, false},
+		{[]string{"foo2png", "-t", "{mime}", "-e", "{ext}"}, []string{"foo2png", "-t", "application/x-foo", "-e", "foo"}, true},
+		{[]string{"foo2png", "{unknown}", "{{dpi}}"}, []string{"foo2png", "{unknown}", "{150}"}, true},
+	}
+	for i, test := range tests {
+		p := &plugin{command: test.command}
+		if params := p.params("/a b/c.foo", "application/x-foo", "foo", 150); !slices.Equal(params, test.exp) {
+			t.Errorf("test %d expected %q, got: %q", i, test.exp, params)
+		}
+		if stdin := p.stdin(); stdin != test.stdin {
+			t.Errorf("test %d expected stdin %t, got: %t", i, test.stdin, stdin)
+		}
+	}
+}
+
+func TestDecodePlugin(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skipf("sh not available: %v", err)
+	}
+	dir := t.TempDir()
+	buf := new(bytes.Buffer)
+	img := testImage(3, 2, func(int, int) color.NRGBA { return color.NRGBA{0xff, 0, 0, 0xff} })
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	pathName := filepath.Join(dir, "a.foo")
+	if err := os.WriteFile(pathName, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	tests := []struct {
+		command  []string
+		pathName string
+		stdin    []byte
+		err      bool
+	}{
+		// stdin mode reads the file from stdin, not the path
+		{[]string{"sh", "-c", "cat"}, filepath.Join(dir, "missing.foo"), buf.Bytes(), false},
+		// path mode reads the file from the path, not stdin
+		{[]string{"sh", "-c", `cat "$1"`, "sh", "{path}"}, pathName, nil, false},
+		{[]string{"sh", "-c", `test "$1" = foo && cat "$2"`, "sh", "{ext}", "{path}"}, pathName, nil, false},
+		{[]string{"sh", "-c", `cat "$1"`, "sh", "{path}"}, filepath.Join(dir, "missing.foo"), buf.Bytes(), true},
+		{[]string{"sh", "-c", "echo failed >&2; exit 1"}, pathName, buf.Bytes(), true},
+		{[]string{"sh", "-c", "true"}, pathName, buf.Bytes(), true},
+		{[]string{filepath.Join(dir, "missing")}, pathName, buf.Bytes(), true},
+	}
+	for i, test := range tests {
+		p, err := newPlugin("foo", map[string][]string{
+			"ext":     {"foo"},
+			"command": test.command,
+		})
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		args := &Args{
+			ctx:    context.Background(),
+			logger: t.Logf,
+			config: &config{plugins: []*plugin{p}},
+		}
+		img, err := args.decodePlugin(test.pathName, "application/octet-stream", io.NopCloser(bytes.NewReader(test.stdin)))
+		args.removeTemps()
+		switch {
+		case test.err && err == nil:
+			t.Errorf("test %d expected error, got: %v", i, img.Bounds())
+		case !test.err && err != nil:
+			t.Errorf("test %d expected no error, got: %v", i, err)
+		case err == nil && (img.Bounds().Dx() != 3 || img.Bounds().Dy() != 2):
+			t.Errorf("test %d expected 3x2, got: %v", i, img.Bounds())
+		}
+	}
+	// no matching plugin
+	args := &Args{ctx: context.Background(), logger: t.Logf, config: &config{}}
+	if _, err := args.decodePlugin(pathName, "application/x-bar", io.NopCloser(bytes.NewReader(nil))); err == nil {
+		t.Errorf("expected error")
+	}
+}
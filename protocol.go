@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kenshaw/rasterm"
+	"golang.org/x/term"
+)
+
+// kitty returns true when images are drawn using the kitty graphics
+// protocol.
+func (args *Args) kitty() bool {
+	switch {
+	case args.ANSI:
+		return false
+	case args.protocol == rasterm.Default:
+		return rasterm.Kitty.Available()
+	}
+	return args.protocol == rasterm.Kitty
+}
+
+// probe writes the graphics protocols detected by rasterm to w, and the
+// environment and terminal responses used to detect them.
+func (args *Args) probe(w io.Writer) error {
+	fmt.Fprintln(w, "environment:")
+	for _, name := range probeEnv {
+		if v, ok := os.LookupEnv(name); ok {
+			fmt.Fprintf(w, "  %-16s %s\n", name+":", v)
+		}
+	}
+	fmt.Fprintln(w, "terminal:")
+	field := func(name string, v any) {
+		fmt.Fprintf(w, "  %-16s %v\n", name+":", v)
+	}
+	field("stdout tty", term.IsTerminal(int(os.Stdout.Fd())))
+	if cols, rows, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+		field("size", fmt.Sprintf("%dx%d", cols, rows))
+	}
+	if cw, ch, ok := cellSize(); ok {
+		field("cell size", fmt.Sprintf("%dx%d", cw, ch))
+	}
+	da, attrs := queryAttributes()
+	if da == "" {
+		da = "no response"
+	}
+	field("da1", strconv.QuoteToASCII(da))
+	field("da1 sixel", sixelAttribute(attrs))
+	// protocols, as detected by rasterm, where the default is the first
+	// available protocol
+	typ, ok := protocols[strings.ToLower(args.Protocol)]
+	switch {
+	case !ok:
+		return fmt.Errorf("invalid protocol %q", args.Protocol)
+	case args.ANSI, args.Braille:
+		typ = rasterm.None
+	case typ == rasterm.Default && (!term.IsTerminal(int(os.Stdout.Fd())) || !rasterm.Available()):
+		typ = rasterm.None
+	}
+	fmt.Fprintln(w, "protocols:")
+	for _, t := range []rasterm.TermType{rasterm.Kitty, rasterm.ITerm, rasterm.Sixel} {
+		available := "no"
+		if t.Available() {
+			if available = "yes"; typ == rasterm.Default {
+				typ = t
+			}
+		}
+		field(t.String(), available)
+	}
+	colors := "256 colors"
+	if ansiTruecolor() {
+		colors = "24-bit color"
+	}
+	field("ansi", "yes ("+colors+")")
+	selected := typ.String()
+	if typ == rasterm.None || typ == rasterm.Default {
+		selected = "ansi"
+	}
+	field("selected", selected)
+	return nil
+}
+
+// sixelAttribute returns true when the terminal's primary device attributes
+// include sixel graphics. The first attribute is the terminal's id.
+func sixelAttribute(attrs []int) bool {
+	return len(attrs) > 1 && slices.Contains(attrs[1:], 4)
+}
+
+// protocols are the graphics protocols. The ansi protocol is rasterm.None.
+var protocols = map[string]rasterm.TermType{
+	"":      rasterm.Default,
+	"auto":  rasterm.Default,
+	"kitty": rasterm.Kitty,
+	"iterm": rasterm.ITerm,
+	"sixel": rasterm.Sixel,
+	"ansi":  rasterm.None,
+}
+
+// probeEnv are the environment variables used to detect graphics protocols.
+var probeEnv = []string{
+	"TERM",
+	"TERM_PROGRAM",
+	"TERM_GRAPHICS",
+	"COLORTERM",
+	"LC_TERMINAL",
+	"KITTY_PID",
+	"KITTY_WINDOW_ID",
+	"TMUX",
+	"STY",
+}
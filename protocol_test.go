@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestProbe(t *testing.T) {
+	tests := []struct {
+		env      map[string]string
+		protocol string
+		ansi     bool
+		exp      map[string]string
+	}{
+		{nil, "", false, map[string]string{"kitty": "no", "iterm": "no", "sixel": "no", "selected": "ansi"}},
+		{map[string]string{"KITTY_PID": "1", "KITTY_WINDOW_ID": "1"}, "", false, map[string]string{"kitty": "yes", "iterm": "no"}},
+		{map[string]string{"KITTY_PID": "1"}, "", false, map[string]string{"kitty": "no"}},
+		{map[string]string{"TERM": "xterm-kitty"}, "", false, map[string]string{"kitty": "yes", "iterm": "no"}},
+		{map[string]string{"TERM_PROGRAM": "ghostty"}, "", false, map[string]string{"kitty": "yes", "iterm": "no"}},
+		{map[string]string{"TERM_PROGRAM": "WezTerm"}, "", false, map[string]string{"kitty": "no", "iterm": "yes"}},
+		{map[string]string{"LC_TERMINAL": "iTerm2"}, "", false, map[string]string{"kitty": "no", "iterm": "yes"}},
+		{map[string]string{"TERM": "mintty"}, "", false, map[string]string{"kitty": "no", "iterm": "yes"}},
+		{map[string]string{"TERM": "xterm-256color"}, "", false, map[string]string{"kitty": "no", "iterm": "no"}},
+		// stdout is not a terminal, so auto detection falls back to ansi
+		{map[string]string{"TERM": "xterm-kitty"}, "auto", false, map[string]string{"selected": "ansi"}},
+		{nil, "sixel", false, map[string]string{"sixel": "no", "selected": "sixel"}},
+		{nil, "KITTY", false, map[string]string{"selected": "kitty"}},
+		{nil, "iterm", false, map[string]string{"selected": "iterm"}},
+		{nil, "ansi", false, map[string]string{"selected": "ansi"}},
+		{nil, "kitty", true, map[string]string{"selected": "ansi"}},
+	}
+	for i, test := range tests {
+		for _, name := range probeEnv {
+			t.Setenv(name, "")
+		}
+		for k, v := range test.env {
+			t.Setenv(k, v)
+		}
+		buf := new(bytes.Buffer)
+		args := &Args{Protocol: test.protocol, ANSI: test.ansi}
+		if err := args.probe(buf); err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		for name, v := range test.exp {
+			if s := fmt.Sprintf("\n  %-16s %s\n", name+":", v); !strings.Contains(buf.String(), s) {
+				t.Errorf("test %d expected %q, got:\n%s", i, s, buf.String())
+			}
+		}
+	}
+	if err := (&Args{Protocol: "bogus"}).probe(new(bytes.Buffer)); err == nil {
+		t.Errorf("expected error")
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"image"
+	"os"
+	"time"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/term"
+)
+
+// scale scales the image down to fit within the display box. Zoomed images
+// are not scaled, as the zoom factor sets their size.
+func (args *Args) scale(img image.Image) image.Image {
+	if args.NoScale || args.Zoom != 1 {
+		return img
+	}
+	b := img.Bounds()
+	maxWidth, maxHeight := args.fitBox()
+	if maxWidth == 0 {
+		maxWidth = b.Dx()
+	}
+	if maxHeight == 0 {
+		maxHeight = b.Dy()
+	}
+	width, height := fitSize(b.Dx(), b.Dy(), maxWidth, maxHeight)
+	if width == b.Dx() && height == b.Dy() {
+		return img
+	}
+	start := time.Now()
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	args.logger("scale %dx%d to %dx%d: %v", b.Dx(), b.Dy(), width, height, time.Since(start))
+	return dst
+}
+
+// fitBox returns the maximum width and height to display images, using the
+// display width and height when specified, and otherwise the terminal's size
+// in pixels, leaving rows for the header and prompt. The terminal's size is
+// not used when writing to an output file. A zero width or height is
+// unbounded.
+func (args *Args) fitBox() (int, int) {
+	width, height := int(args.Width), int(args.Height)
+	if args.Output == "" {
+		if cw, ch, ok := args.cellSize(); ok {
+			if cols, rows, ok := args.termSize(); ok {
+				if width == 0 {
+					width = cols * cw
+				}
+				if height == 0 {
+					height = max(rows-2, 1) * ch
+				}
+			}
+		}
+	}
+	if width != 0 {
+		width = max(width, int(args.MinWidth))
+	}
+	if height != 0 {
+		height = max(height, int(args.MinHeight))
+	}
+	return width, height
+}
+
+// cellSize returns the size in pixels of a terminal cell, or of the pixels
+// drawn per cell when rendering ansi text.
+func (args *Args) cellSize() (int, int, bool) {
+	if args.ANSI {
+		cw, ch := args.ansiCellSize()
+		return cw, ch, true
+	}
+	return cellSize()
+}
+
+// termSize returns the terminal's size in cells. When rendering ansi text
+// and stdout is not a terminal, the size is 80x24.
+func (args *Args) termSize() (int, int, bool) {
+	cols, rows, err := term.GetSize(int(os.Stdout.Fd()))
+	switch {
+	case err == nil:
+		return cols, rows, true
+	case args.ANSI:
+		return 80, 24, true
+	}
+	return 0, 0, false
+}
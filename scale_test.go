@@ -0,0 +1,48 @@
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+func TestFitBox(t *testing.T) {
+	tests := []struct {
+		args *Args
+		w, h int
+	}{
+		{&Args{Output: "a.png", ANSI: true}, 0, 0},
+		{&Args{Output: "a.png", Width: 100, Height: 50}, 100, 50},
+		{&Args{Output: "a.png", Width: 10, MinWidth: 20, MinHeight: 30}, 20, 0},
+		{&Args{Output: "a.png", ANSI: true, Braille: true, Height: 40}, 0, 40},
+		// stdout is not a terminal, so ansi text uses 80x24
+		{&Args{ANSI: true}, 80, 44},
+		{&Args{ANSI: true, Braille: true}, 160, 88},
+		{&Args{ANSI: true, Width: 30}, 30, 44},
+	}
+	for i, test := range tests {
+		if w, h := test.args.fitBox(); w != test.w || h != test.h {
+			t.Errorf("test %d expected %dx%d, got: %dx%d", i, test.w, test.h, w, h)
+		}
+	}
+}
+
+func TestScale(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 400, 200))
+	tests := []struct {
+		args *Args
+		exp  image.Point
+	}{
+		{&Args{Output: "a.png", Zoom: 1}, image.Pt(400, 200)},
+		{&Args{Output: "a.png", Zoom: 1, Width: 100}, image.Pt(100, 50)},
+		{&Args{Output: "a.png", Zoom: 1, Width: 800, Height: 50}, image.Pt(100, 50)},
+		{&Args{Output: "a.png", Zoom: 1, Width: 100, NoScale: true}, image.Pt(400, 200)},
+		{&Args{Output: "a.png", Zoom: 2, Width: 100}, image.Pt(400, 200)},
+		{&Args{Zoom: 1, ANSI: true}, image.Pt(80, 40)},
+	}
+	for i, test := range tests {
+		test.args.logger = t.Logf
+		if size := test.args.scale(img).Bounds().Size(); size != test.exp {
+			t.Errorf("test %d expected %v, got: %v", i, test.exp, size)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+//go:build !unix
+
+package main
+
+import (
+	"os"
+)
+
+// notifyResize relays terminal resize signals to ch.
+//
+// Not supported on this platform.
+func notifyResize(chan<- os.Signal) {
+}
+
+// stopResize stops relaying terminal resize signals to ch.
+func stopResize(chan<- os.Signal) {
+}
+
+// queryAttributes queries the terminal for its primary device attributes
+// (DA1), returning the response and its attributes.
+//
+// Not supported on this platform.
+func queryAttributes() (string, []int) {
+	return "", nil
+}
+
+// cellSize returns the terminal's cell width and height in pixels.
+//
+// Not supported on this platform.
+func cellSize() (int, int, bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,132 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// notifyResize relays terminal resize signals to ch.
+func notifyResize(ch chan<- os.Signal) {
+	signal.Notify(ch, syscall.SIGWINCH)
+}
+
+// stopResize stops relaying terminal resize signals to ch.
+func stopResize(ch chan<- os.Signal) {
+	signal.Stop(ch)
+}
+
+// cellSize returns the terminal's cell width and height in pixels. Queries
+// the terminal when the kernel does not report the terminal's pixel size.
+func cellSize() (int, int, bool) {
+	ws, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+	switch {
+	case err != nil || ws.Col == 0 || ws.Row == 0:
+		return 0, 0, false
+	case ws.Xpixel != 0 && ws.Ypixel != 0:
+		return int(ws.Xpixel / ws.Col), int(ws.Ypixel / ws.Row), true
+	}
+	cellOnce.Do(func() {
+		cellWidth, cellHeight = queryCellSize(int(ws.Col), int(ws.Row))
+	})
+	return cellWidth, cellHeight, cellWidth != 0 && cellHeight != 0
+}
+
+// queryCellSize queries the terminal for its cell size in pixels, using the
+// cell size (CSI 16t) or window size (CSI 14t) reports.
+func queryCellSize(cols, rows int) (int, int) {
+	return parseCellSize(queryTerm("\x1b[16t\x1b[14t"), cols, rows)
+}
+
+// queryAttributes queries the terminal for its primary device attributes
+// (DA1), returning the response and its attributes.
+func queryAttributes() (string, []int) {
+	m := daRE.FindSubmatch(queryTerm(""))
+	if m == nil {
+		return "", nil
+	}
+	var attrs []int
+	for s := range strings.SplitSeq(string(m[1]), ";") {
+		if i, err := strconv.Atoi(s); err == nil {
+			attrs = append(attrs, i)
+		}
+	}
+	return string(m[0]), attrs
+}
+
+// queryTerm writes the request to the terminal, returning the terminal's
+// responses. A primary device attributes request (DA1), which all terminals
+// answer, ends the query.
+func queryTerm(req string) []byte {
+	fd, err := unix.Open("/dev/tty", unix.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil
+	}
+	defer unix.Close(fd)
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil
+	}
+	defer term.Restore(fd, state)
+	if _, err := unix.Write(fd, []byte(req+"\x1b[c")); err != nil {
+		return nil
+	}
+	var buf []byte
+	b := make([]byte, 256)
+	for deadline := time.Now().Add(250 * time.Millisecond); !daRE.Match(buf); {
+		ms := int(time.Until(deadline) / time.Millisecond)
+		if ms <= 0 {
+			break
+		}
+		n, err := unix.Poll([]unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}, ms)
+		switch {
+		case err == unix.EINTR:
+			continue
+		case err != nil, n == 0:
+			return buf
+		}
+		if n, err = unix.Read(fd, b); err != nil {
+			break
+		}
+		buf = append(buf, b[:n]...)
+	}
+	return buf
+}
+
+// parseCellSize parses the cell size from the terminal's cell size or window
+// size reports.
+func parseCellSize(buf []byte, cols, rows int) (int, int) {
+	if m := cellRE.FindSubmatch(buf); m != nil {
+		h, _ := strconv.Atoi(string(m[1]))
+		w, _ := strconv.Atoi(string(m[2]))
+		return w, h
+	}
+	if m := windowRE.FindSubmatch(buf); m != nil {
+		h, _ := strconv.Atoi(string(m[1]))
+		w, _ := strconv.Atoi(string(m[2]))
+		return w / cols, h / rows
+	}
+	return 0, 0
+}
+
+var (
+	cellRE   = regexp.MustCompile(`\x1b\[6;(\d+);(\d+)t`)
+	windowRE = regexp.MustCompile(`\x1b\[4;(\d+);(\d+)t`)
+	daRE     = regexp.MustCompile(`\x1b\[\?([\d;]*)c`)
+)
+
+var (
+	cellOnce   sync.Once
+	cellWidth  int
+	cellHeight int
+)
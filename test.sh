@@ -11,7 +11,8 @@ IVBIN=$(realpath $IVBIN)
 urls=(
   "wifi://testssid"
   "WIFI:testssid"
-  "https://google.com"
+  "qr:https://google.com"
+  "https://raw.githubusercontent.com/kenshaw/iv/master/testdata/tux/2.png"
   "data:image/svg+xml;base64,PHN2ZyB4bWxucz0iaHR0cDovL3d3dy53My5vcmcvMjAwMC9zdmciIHdpZHRoPSIxMDAiIGhlaWdodD0iMTAwIj48Y2lyY2xlIGN4PSI1MCIgY3k9IjUwIiByPSI0MCIgc3Ryb2tlPSJncmVlbiIgc3Ryb2tlLXdpZHRoPSI0IiBmaWxsPSJ5ZWxsb3ciIC8+PC9zdmc+"
   "data:image/svg+xml;base64,PHN2ZyB4bWxucz0iaHR0cDovL3d3dy53My5vcmcvMjAwMC9zdmciIHdpZHRoPSI2NHB4IiBoZWlnaHQ9IjY0cHgiIHZpZXdCb3g9IjAgMCA2NCA2NCIgdmVyc2lvbj0iMS4xIj48cmVjdCBmaWxsPSIjNTBjODQ4IiBjeD0iMzIiIGN5PSIzMiIgd2lkdGg9IjY0IiBoZWlnaHQ9IjY0IiByPSIzMiIvPjx0ZXh0IHg9IjUwJSIgeT0iNTAlIiBzdHlsZT0iY29sb3I6ICNmZmY7IGxpbmUtaGVpZ2h0OiAxOyBmb250LWZhbWlseTogJ1JlZEhhdFRleHQnLCdPdmVycGFzcycsb3ZlcnBhc3MsaGVsdmV0aWNhLGFyaWFsLHNhbnMtc2VyaWY7ICIgZmlsbD0iI2ZmZiIgYWxpZ25tZW50LWJhc2VsaW5lPSJtaWRkbGUiIGRvbWluYW50LWJhc2VsaW5lPSJtaWRkbGUiIHRleHQtYW5jaG9yPSJtaWRkbGUiIGZvbnQtc2l6ZT0iMjgiIGZvbnQtd2VpZ2h0PSI0MDAiIGR5PSIuMWVtIj5LUzwvdGV4dD48L3N2Zz4="
   "data:image/svg+xml,%3Csvg%20xmlns%3D%22http%3A//www.w3.org/2000/svg%22%20width%3D%2264px%22%20height%3D%2264px%22%20viewBox%3D%220%200%2064%2064%22%20version%3D%221.1%22%3E%3Crect%20fill%3D%22%2350c848%22%20cx%3D%2232%22%20cy%3D%2232%22%20width%3D%2264%22%20height%3D%2264%22%20r%3D%2232%22/%3E%3Ctext%20x%3D%2250%25%22%20y%3D%2250%25%22%20style%3D%22color%3A%20%23fff%3B%20line-height%3A%201%3B%20font-family%3A%20%27RedHatText%27%2C%27Overpass%27%2Coverpass%2Chelvetica%2Carial%2Csans-serif%3B%20%22%20fill%3D%22%23fff%22%20alignment-baseline%3D%22middle%22%20dominant-baseline%3D%22middle%22%20text-anchor%3D%22middle%22%20font-size%3D%2228%22%20font-weight%3D%22400%22%20dy%3D%22.1em%22%3EKS%3C/text%3E%3C/svg%3E"
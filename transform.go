@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
+)
+
+// transform crops, rotates, flips, flops, and zooms the image, in that
+// order, and then adjusts the image's colors.
+func (args *Args) transform(img image.Image) (image.Image, error) {
+	if args.Crop == "" && args.Rotate == 0 && !args.Flip && !args.Flop && args.Zoom == 1 {
+		return args.adjust(img)
+	}
+	start := time.Now()
+	b := img.Bounds()
+	if args.Crop != "" {
+		c, err := parseCrop(args.Crop)
+		if err != nil {
+			return nil, err
+		}
+		if img, err = cropImage(img, c.rect(b)); err != nil {
+			return nil, err
+		}
+	}
+	if args.Rotate != 0 {
+		img = rotate(img, args.Rotate)
+	}
+	if args.Flip {
+		img = orient(img, 4)
+	}
+	if args.Flop {
+		img = orient(img, 2)
+	}
+	if args.Zoom != 1 {
+		if args.Zoom <= 0 {
+			return nil, fmt.Errorf("invalid zoom %g", args.Zoom)
+		}
+		var err error
+		if img, err = zoom(img, args.Zoom); err != nil {
+			return nil, err
+		}
+	}
+	args.logger("transform %dx%d to %dx%d: %v", b.Dx(), b.Dy(), img.Bounds().Dx(), img.Bounds().Dy(), time.Since(start))
+	return args.adjust(img)
+}
+
+// crop is a crop region, in pixels or percentages of the image's size.
+type crop struct {
+	w, h, x, y cropLength
+}
+
+// parseCrop parses a crop region (WxH+X+Y), where each value is in pixels
+// or is a percentage (ex: 50%x50%+25%+25%). The offset is optional.
+func parseCrop(s string) (*crop, error) {
+	m := cropRE.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("invalid crop %q", s)
+	}
+	var v [4]cropLength
+	for i, str := range m[1:] {
+		if str == "" {
+			continue
+		}
+		n := len(str)
+		if str[n-1] == '%' {
+			v[i].percent, n = true, n-1
+		}
+		var err error
+		if v[i].v, err = strconv.ParseFloat(str[:n], 64); err != nil {
+			return nil, fmt.Errorf("invalid crop %q: %w", s, err)
+		}
+	}
+	if v[0].v == 0 || v[1].v == 0 {
+		return nil, fmt.Errorf("invalid crop %q: empty region", s)
+	}
+	return &crop{v[0], v[1], v[2], v[3]}, nil
+}
+
+// rect returns the crop region within the bounds.
+func (c *crop) rect(b image.Rectangle) image.Rectangle {
+	w, h := b.Dx(), b.Dy()
+	x, y := c.x.pixels(w), c.y.pixels(h)
+	return image.Rect(x, y, x+c.w.pixels(w), y+c.h.pixels(h)).Add(b.Min).Intersect(b)
+}
+
+// cropLength is a crop length, in pixels or as a percentage.
+type cropLength struct {
+	v       float64
+	percent bool
+}
+
+// pixels returns the length in pixels, where n is the image's length.
+func (l cropLength) pixels(n int) int {
+	if l.percent {
+		return int(math.Round(l.v * float64(n) / 100))
+	}
+	return int(l.v)
+}
+
+// cropImage returns the region of the image.
+func cropImage(img image.Image, r image.Rectangle) (image.Image, error) {
+	if r.Empty() {
+		return nil, errors.New("crop region is outside of the image")
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, r.Min, draw.Src)
+	return dst, nil
+}
+
+// rotate rotates the image clockwise by the degrees. Rotations other than
+// multiples of 90 degrees are expanded to fit the rotated image, with
+// transparent corners.
+func rotate(img image.Image, degrees float64) image.Image {
+	switch degrees = math.Mod(math.Mod(degrees, 360)+360, 360); degrees {
+	case 0:
+		return img
+	case 90:
+		return orient(img, 6)
+	case 180:
+		return orient(img, 3)
+	case 270:
+		return orient(img, 8)
+	}
+	b := img.Bounds()
+	sin, cos := math.Sincos(degrees * math.Pi / 180)
+	w, h := float64(b.Dx()), float64(b.Dy())
+	dw, dh := math.Abs(w*cos)+math.Abs(h*sin), math.Abs(w*sin)+math.Abs(h*cos)
+	dst := image.NewNRGBA(image.Rect(0, 0, int(math.Ceil(dw)), int(math.Ceil(dh))))
+	// rotate about the center of the image, translated to the center of dst
+	cx, cy := float64(b.Min.X)+w/2, float64(b.Min.Y)+h/2
+	m := f64.Aff3{
+		cos, -sin, dw/2 - cos*cx + sin*cy,
+		sin, cos, dh/2 - sin*cx - cos*cy,
+	}
+	draw.BiLinear.Transform(dst, m, img, b, draw.Src, nil)
+	return dst
+}
+
+// zoom scales the image by the factor. Uses nearest neighbour scaling for
+// integer factors, so that pixel art stays sharp. Returns an error when the
+// zoomed image would be larger than maxZoomPixels.
+func zoom(img image.Image, factor float64) (image.Image, error) {
+	b := img.Bounds()
+	fw, fh := math.Round(float64(b.Dx())*factor), math.Round(float64(b.Dy())*factor)
+	if !(fw*fh <= maxZoomPixels) {
+		return nil, fmt.Errorf("invalid zoom %g: %dx%d image is too large", factor, b.Dx(), b.Dy())
+	}
+	w, h := max(1, int(fw)), max(1, int(fh))
+	var interp draw.Interpolator = draw.CatmullRom
+	if 1 < factor && factor == math.Trunc(factor) {
+		interp = draw.NearestNeighbor
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	interp.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst, nil
+}
+
+// maxZoomPixels is the maximum number of pixels in a zoomed image.
+const maxZoomPixels = 1 << 28
+
+// cropRE matches crop regions.
+var cropRE = regexp.MustCompile(`^(\d+(?:\.\d+)?%?)x(\d+(?:\.\d+)?%?)(?:\+(\d+(?:\.\d+)?%?)\+(\d+(?:\.\d+)?%?))?$`)
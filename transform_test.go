@@ -0,0 +1,142 @@
+package main
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestParseCrop(t *testing.T) {
+	b := image.Rect(0, 0, 200, 100)
+	tests := []struct {
+		s   string
+		exp image.Rectangle
+		err bool
+	}{
+		{"10x20", image.Rect(0, 0, 10, 20), false},
+		{"10x20+5+6", image.Rect(5, 6, 15, 26), false},
+		{"50%x50%", image.Rect(0, 0, 100, 50), false},
+		{"50%x50%+25%+25%", image.Rect(50, 25, 150, 75), false},
+		{"12.5%x10+0+10%", image.Rect(0, 10, 25, 20), false},
+		{"300x300", b, false},
+		{"100x100+150+50", image.Rect(150, 50, 200, 100), false},
+		{"10x10+300+300", image.Rectangle{}, false},
+		{"", image.Rectangle{}, true},
+		{"10", image.Rectangle{}, true},
+		{"0x10", image.Rectangle{}, true},
+		{"10x0%", image.Rectangle{}, true},
+		{"10x10+5", image.Rectangle{}, true},
+		{"-10x10", image.Rectangle{}, true},
+		{"10x10-5-5", image.Rectangle{}, true},
+		{"ax10", image.Rectangle{}, true},
+	}
+	for i, test := range tests {
+		c, err := parseCrop(test.s)
+		switch {
+		case test.err && err == nil:
+			t.Errorf("test %d %q expected error, got: %v", i, test.s, c)
+		case !test.err && err != nil:
+			t.Errorf("test %d %q expected no error, got: %v", i, test.s, err)
+		case err == nil:
+			if r := c.rect(b); r != test.exp && !(r.Empty() && test.exp.Empty()) {
+				t.Errorf("test %d %q expected %v, got: %v", i, test.s, test.exp, r)
+			}
+		}
+	}
+}
+
+func TestCropOffset(t *testing.T) {
+	b := image.Rect(10, 20, 110, 70)
+	c, err := parseCrop("50%x50%+10+10")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if r, exp := c.rect(b), image.Rect(20, 30, 70, 55); r != exp {
+		t.Errorf("expected %v, got: %v", exp, r)
+	}
+}
+
+func TestRotateZoom(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	tests := []struct {
+		degrees float64
+		zoom    float64
+		exp     image.Point
+	}{
+		{0, 1, image.Pt(40, 20)},
+		{90, 1, image.Pt(20, 40)},
+		{-90, 1, image.Pt(20, 40)},
+		{180, 1, image.Pt(40, 20)},
+		{450, 1, image.Pt(20, 40)},
+		{45, 1, image.Pt(43, 43)},
+		{0, 2, image.Pt(80, 40)},
+		{0, 1.5, image.Pt(60, 30)},
+		{0, 0.5, image.Pt(20, 10)},
+		{0, 0.01, image.Pt(1, 1)},
+		{90, 3, image.Pt(60, 120)},
+	}
+	for i, test := range tests {
+		dst := rotate(img, test.degrees)
+		if test.zoom != 1 {
+			var err error
+			if dst, err = zoom(dst, test.zoom); err != nil {
+				t.Fatalf("test %d expected no error, got: %v", i, err)
+			}
+		}
+		if size := dst.Bounds().Size(); size != test.exp {
+			t.Errorf("test %d (%g, %g) expected %v, got: %v", i, test.degrees, test.zoom, test.exp, size)
+		}
+	}
+}
+
+func TestZoomInterpolation(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	copy(src.Pix, []uint8{0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff})
+	tests := []struct {
+		factor  float64
+		nearest bool
+	}{
+		{2, true},
+		{4, true},
+		{8, true},
+		{16, true},
+		{32, true},
+		{2.5, false},
+		{0.5, false},
+	}
+	for i, test := range tests {
+		img, err := zoom(src, test.factor)
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		dst := img.(*image.NRGBA)
+		nearest := true
+		for x := range dst.Rect.Dx() {
+			if v := dst.Pix[dst.PixOffset(x, 0)]; v != 0 && v != 0xff {
+				nearest = false
+			}
+		}
+		if nearest != test.nearest {
+			t.Errorf("test %d (%g) expected nearest %t, got: %t", i, test.factor, test.nearest, nearest)
+		}
+	}
+}
+
+func TestZoomLimit(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	tests := []struct {
+		factor float64
+		err    bool
+	}{
+		{32, false},
+		{1025, true},
+		{1e9, true},
+		{math.Inf(1), true},
+		{math.NaN(), true},
+	}
+	for i, test := range tests {
+		if _, err := zoom(src, test.factor); (err != nil) != test.err {
+			t.Errorf("test %d (%g) expected error %t, got: %v", i, test.factor, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// ffmpegStoryboard extracts evenly spaced frames across the duration of the
+// video, composing them into a single image with each frame labeled by its
+// time code.
+func (args *Args) ffmpegStoryboard(pathName string) (image.Image, error) {
+	dur, err := args.ffprobeDuration(pathName)
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("storyboard: %w", err)
+	case dur <= 0:
+		return nil, errors.New("storyboard: unknown duration")
+	}
+	start := time.Now()
+	n := int(args.Storyboard)
+	var cells []gridCell
+	for i := range n {
+		// center each frame in its slice of the duration
+		tc := time.Duration((float64(i) + 0.5) / float64(n) * float64(dur))
+		img, err := args.ffmpegFrame(pathName, strconv.FormatFloat(tc.Seconds(), 'f', 3, 64))
+		if err != nil {
+			return nil, fmt.Errorf("storyboard: frame %d: %w", i+1, err)
+		}
+		cells = append(cells, gridCell{
+			img:     img,
+			caption: formatTimestamp(tc),
+		})
+	}
+	args.logger("storyboard frames: %v", time.Since(start))
+	// size tiles using the aspect ratio of the first frame
+	b := cells[0].img.Bounds()
+	width, height := storyboardWidth, storyboardWidth
+	if b.Dx() != 0 {
+		height = max(1, storyboardWidth*b.Dy()/b.Dx())
+	}
+	cols := int(args.GridCols)
+	if cols == 0 {
+		cols = min(n, int(math.Ceil(math.Sqrt(float64(n)))))
+	}
+	return args.composeGrid(cells, width, height, cols), nil
+}
+
+// storyboardWidth is the width of storyboard tiles.
+const storyboardWidth = 320
+
+// formatTimestamp formats a duration as a timestamp with tenths of a second,
+// including hours only when needed.
+func formatTimestamp(d time.Duration) string {
+	d = d.Round(100 * time.Millisecond)
+	h, m := int(d/time.Hour), int(d%time.Hour/time.Minute)
+	s, t := int(d%time.Minute/time.Second), int(d%time.Second/(100*time.Millisecond))
+	if h != 0 {
+		return fmt.Sprintf("%d:%02d:%02d.%d", h, m, s, t)
+	}
+	return fmt.Sprintf("%02d:%02d.%d", m, s, t)
+}
+
+// video is a snapshot of a video that can be played.
+type video struct {
+	image.Image
+	pathName string
+}
+
+// isVideo returns true when the image is a playable video and stdout is a
+// terminal.
+func isVideo(img image.Image) (*video, bool) {
+	v, ok := img.(*video)
+	return v, ok && term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// playVideo plays the video in place to w, streaming scaled frames from the
+// ffmpeg command. Frames are transformed and drawn at the video's frame rate,
+// dropping frames when drawing falls behind. Space pauses and resumes, and q
+// quits when stdin is a terminal.
+func (args *Args) playVideo(w io.Writer, v *video) error {
+	fps := args.ffprobeFrameRate(v.pathName)
+	b := v.Bounds()
+	width, height := args.playSize(b.Dx(), b.Dy())
+	args.logger("play: %dx%d at %.3f fps", width, height, fps)
+	ctx, cancel := context.WithCancel(args.ctx)
+	defer cancel()
+	frames, err := args.ffmpegStream(ctx, v.pathName, width, height)
+	if err != nil {
+		return err
+	}
+	// keys
+	var keys chan key
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return fmt.Errorf("play: %w", err)
+		}
+		defer term.Restore(fd, state)
+		// raw mode disables output processing
+		w = crlfWriter{w}
+		keys = make(chan key)
+		go readKeys(os.Stdin, keys)
+	}
+	var p *placer
+	defer func() {
+		if p != nil {
+			p.close()
+		}
+	}()
+	interval := time.Duration(float64(time.Second) / fps)
+	var start time.Time
+	for i := 0; ; i++ {
+		var img image.Image
+		select {
+		case <-ctx.Done():
+			return nil
+		case k, ok := <-keys:
+			switch {
+			case !ok:
+				keys = nil
+			case k == keyQuit:
+				return nil
+			case k == keyPause:
+				// pause until space is pressed again
+				paused := time.Now()
+				for k = keyNone; k != keyPause; {
+					select {
+					case <-ctx.Done():
+						return nil
+					case k, ok = <-keys:
+						if !ok || k == keyQuit {
+							return nil
+						}
+					}
+				}
+				start = start.Add(time.Since(paused))
+			}
+			i--
+			continue
+		case f, ok := <-frames:
+			if !ok {
+				return nil
+			}
+			img = f
+		}
+		// pace from the first frame, as ffmpeg takes time to start
+		if i == 0 {
+			start = time.Now()
+		}
+		due := start.Add(time.Duration(i) * interval)
+		if time.Since(due) > interval {
+			args.logger("play: dropped frame %d", i)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Until(due)):
+		}
+		if img, err = args.transform(img); err != nil {
+			return err
+		}
+		if p == nil {
+			p = args.newPlacer(w, img, false)
+		}
+		if err := p.draw(img); err != nil {
+			return err
+		}
+	}
+}
+
+// ffmpegStream streams the video's frames scaled to width and height using
+// the ffmpeg command, starting at the time code and limited to the play
+// duration. The frame channel is closed when the stream ends.
+func (args *Args) ffmpegStream(ctx context.Context, pathName string, width, height int) (<-chan image.Image, error) {
+	params := []string{
+		`-hide_banner`,
+		`-loglevel`, `error`,
+	}
+	if tc := args.TimeCode.Duration(); tc != 0 {
+		params = append(params, `-ss`, strconv.FormatFloat(tc.Seconds(), 'f', 3, 64))
+	}
+	if limit := args.PlayLimit.Duration(); limit != 0 {
+		params = append(params, `-t`, strconv.FormatFloat(limit.Seconds(), 'f', 3, 64))
+	}
+	params = append(params,
+		`-i`, pathName,
+		`-an`,
+		`-vf`, fmt.Sprintf(`scale=%d:%d`, width, height),
+		`-pix_fmt`, `rgba`,
+		`-f`, `rawvideo`,
+		`-`,
+	)
+	args.logger("executing: %s %s", ffmpegPath, strings.Join(params, " "))
+	cmd := exec.CommandContext(ctx, ffmpegPath, params...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	frames := make(chan image.Image)
+	go func() {
+		defer close(frames)
+		defer cmd.Wait()
+		for {
+			img := image.NewNRGBA(image.Rect(0, 0, width, height))
+			if _, err := io.ReadFull(stdout, img.Pix); err != nil {
+				if err != io.EOF {
+					args.logger("play: %v", err)
+				}
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case frames <- img:
+			}
+		}
+	}()
+	return frames, nil
+}
+
+// ffprobeFrameRate returns the frame rate of the video's first video stream
+// using the ffprobe command, or 25 when not known.
+func (args *Args) ffprobeFrameRate(pathName string) float64 {
+	const defaultRate = 25
+	if ffprobePath == "" {
+		return defaultRate
+	}
+	params := []string{
+		`-v`, `error`,
+		`-select_streams`, `v:0`,
+		`-show_entries`, `stream=r_frame_rate`,
+		`-of`, `default=nw=1:nk=1`,
+		pathName,
+	}
+	args.logger("ffprobe: executing %s %s", ffprobePath, strings.Join(params, " "))
+	buf, err := exec.CommandContext(args.ctx, ffprobePath, params...).Output()
+	if err != nil {
+		return defaultRate
+	}
+	// rate is a fraction, such as 30000/1001
+	num, den, _ := strings.Cut(strings.TrimSpace(string(buf)), "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n <= 0 {
+		return defaultRate
+	}
+	if d, err := strconv.ParseFloat(den, 64); err == nil && d > 0 {
+		n /= d
+	}
+	return n
+}
+
+// playSize returns the size to play a video having the width and height,
+// fitting the video to the display box when known. Dimensions are even, as
+// required by most ffmpeg pixel formats.
+func (args *Args) playSize(width, height int) (int, int) {
+	maxWidth, maxHeight := args.fitBox()
+	if maxWidth == 0 {
+		maxWidth = 640
+	}
+	if maxHeight == 0 {
+		maxHeight = 480
+	}
+	if !args.NoScale {
+		width, height = fitSize(width, height, maxWidth, maxHeight)
+	}
+	return max(2, width&^1), max(2, height&^1)
+}
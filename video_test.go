@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kenshaw/colors"
+)
+
+func TestFormatTimestamp(t *testing.T) {
+	tests := []struct {
+		d   time.Duration
+		exp string
+	}{
+		{0, "00:00.0"},
+		{1250 * time.Millisecond, "00:01.3"},
+		{59*time.Second + 960*time.Millisecond, "01:00.0"},
+		{12*time.Minute + 3*time.Second, "12:03.0"},
+		{time.Hour + 2*time.Minute + 3*time.Second + 400*time.Millisecond, "1:02:03.4"},
+		{25 * time.Hour, "25:00:00.0"},
+	}
+	for i, test := range tests {
+		if s := formatTimestamp(test.d); s != test.exp {
+			t.Errorf("test %d expected %q, got: %q", i, test.exp, s)
+		}
+	}
+}
+
+func TestFfmpegStoryboard(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skipf("sh not available: %v", err)
+	}
+	dir := t.TempDir()
+	// a 4x2 frame, output by a fake ffmpeg logging its time codes
+	frame, log := filepath.Join(dir, "frame.png"), filepath.Join(dir, "log")
+	f, err := os.Create(frame)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	img := testImage(4, 2, func(int, int) color.NRGBA { return color.NRGBA{0xff, 0, 0, 0xff} })
+	if err := png.Encode(f, img); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	fg, err := colors.Parse("white")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	ffmpeg, ffprobe := filepath.Join(dir, "ffmpeg"), filepath.Join(dir, "ffprobe")
+	script := "#!/bin/sh\necho \"$3\" >> " + log + "\ncat " + frame + "\n"
+	if err := os.WriteFile(ffmpeg, []byte(script), 0o755); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	ffmpegOnce.Do(func() {})
+	prevFfmpeg, prevFfprobe := ffmpegPath, ffprobePath
+	t.Cleanup(func() { ffmpegPath, ffprobePath = prevFfmpeg, prevFfprobe })
+	ffmpegPath, ffprobePath = ffmpeg, ffprobe
+	tests := []struct {
+		duration string
+		n, cols  uint
+		exp      image.Rectangle
+		tcs      []string
+		err      bool
+	}{
+		{"10.000000", 4, 0, image.Rect(0, 0, 664, 376), []string{"1.250", "3.750", "6.250", "8.750"}, false},
+		{"10.000000", 3, 0, image.Rect(0, 0, 664, 376), []string{"1.667", "5.000", "8.333"}, false},
+		{"10.000000", 4, 4, image.Rect(0, 0, 1320, 192), []string{"1.250", "3.750", "6.250", "8.750"}, false},
+		{"2.000000", 1, 0, image.Rect(0, 0, 336, 192), []string{"1.000"}, false},
+		{"0.000000", 4, 0, image.Rectangle{}, nil, true},
+		{"N/A", 4, 0, image.Rectangle{}, nil, true},
+	}
+	for i, test := range tests {
+		script := "#!/bin/sh\necho duration=" + test.duration + "\n"
+		if err := os.WriteFile(ffprobe, []byte(script), 0o755); err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if err := os.Remove(log); err != nil && !os.IsNotExist(err) {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		args := &Args{
+			ctx:        context.Background(),
+			logger:     t.Logf,
+			Fg:         &fg,
+			Storyboard: test.n,
+			GridCols:   test.cols,
+		}
+		img, err := args.ffmpegStoryboard(filepath.Join(dir, "a.mp4"))
+		switch {
+		case test.err && err == nil:
+			t.Errorf("test %d expected error, got: %v", i, img.Bounds())
+			continue
+		case test.err:
+			continue
+		case err != nil:
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if b := img.Bounds(); b != test.exp {
+			t.Errorf("test %d expected %v, got: %v", i, test.exp, b)
+		}
+		buf, err := os.ReadFile(log)
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if tcs := strings.Fields(string(buf)); !slices.Equal(tcs, test.tcs) {
+			t.Errorf("test %d expected %v, got: %v", i, test.tcs, tcs)
+		}
+	}
+}
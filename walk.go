@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// walk walks the directory, returning the files matching the include
+// patterns, or the files with a known extension when there are no include
+// patterns, that do not match the exclude patterns. Hidden files and
+// directories are skipped when requested.
+func (args *Args) walk(root string) ([]target, error) {
+	var targets []target
+	seen := make(map[string]bool)
+	var walk func(string, uint) error
+	walk = func(dir string, depth uint) error {
+		if args.FollowSymlinks {
+			real, err := filepath.EvalSymlinks(dir)
+			switch {
+			case err != nil:
+				return err
+			case seen[real]:
+				args.logger("skipping %q: already visited", dir)
+				return nil
+			}
+			seen[real] = true
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			pathName := filepath.Join(dir, name)
+			rel, err := filepath.Rel(root, pathName)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			switch {
+			case args.NoHidden && strings.HasPrefix(name, "."):
+				continue
+			case matchAny(args.Exclude, name, rel):
+				args.logger("excluding %q", rel)
+				continue
+			}
+			isDir := entry.IsDir()
+			if entry.Type()&fs.ModeSymlink != 0 {
+				fi, err := os.Stat(pathName)
+				if err != nil {
+					args.logger("skipping %q: %v", pathName, err)
+					continue
+				}
+				if isDir = fi.IsDir(); isDir && !args.FollowSymlinks {
+					continue
+				}
+			}
+			switch {
+			case isDir && args.Recursive && (args.MaxDepth == 0 || depth < args.MaxDepth):
+				if err := walk(pathName, depth+1); err != nil {
+					args.logger("skipping %q: %v", pathName, err)
+				}
+			case isDir:
+			case len(args.Include) != 0 && matchAny(args.Include, name, rel),
+				len(args.Include) == 0 && extensions[fileExt(name)]:
+				targets = append(targets, target{path: pathName})
+			}
+		}
+		return nil
+	}
+	if err := walk(root, 1); err != nil {
+		return nil, err
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].path < targets[j].path
+	})
+	return targets, nil
+}
+
+// matchAny returns true when any of the glob patterns match the name or the
+// relative path.
+func matchAny(patterns []string, name, rel string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPatterns checks that the glob patterns are valid.
+func checkPatterns(patterns ...string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{
+		"a.png",
+		"b.xyz",
+		".hidden.png",
+		".dir/c.png",
+		"sub/d.png",
+		"sub/e.jpg",
+		"sub/deep/f.png",
+		"sub/deep/g.png",
+	} {
+		pathName := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(pathName), 0o755); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if err := os.WriteFile(pathName, nil, 0o644); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	for name, dest := range map[string]string{
+		"link":          "sub",
+		"broken.png":    "missing.png",
+		"sub/deep/loop": filepath.Join("..", ".."),
+	} {
+		if err := os.Symlink(dest, filepath.Join(root, filepath.FromSlash(name))); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+	all := []string{".dir/c.png", ".hidden.png", "a.png", "sub/d.png", "sub/deep/f.png", "sub/deep/g.png", "sub/e.jpg"}
+	tests := []struct {
+		args *Args
+		exp  []string
+	}{
+		{&Args{}, []string{".hidden.png", "a.png"}},
+		{&Args{Recursive: true}, all},
+		{&Args{Recursive: true, NoHidden: true}, []string{"a.png", "sub/d.png", "sub/deep/f.png", "sub/deep/g.png", "sub/e.jpg"}},
+		{&Args{Recursive: true, MaxDepth: 1}, []string{".hidden.png", "a.png"}},
+		{&Args{Recursive: true, MaxDepth: 2}, []string{".dir/c.png", ".hidden.png", "a.png", "sub/d.png", "sub/e.jpg"}},
+		{&Args{Recursive: true, MaxDepth: 3}, all},
+		// include on the name and the relative path
+		{&Args{Recursive: true, Include: []string{"*.jpg"}}, []string{"sub/e.jpg"}},
+		{&Args{Recursive: true, Include: []string{"*.xyz"}}, []string{"b.xyz"}},
+		{&Args{Recursive: true, Include: []string{"sub/*.png"}}, []string{"sub/d.png"}},
+		{&Args{Recursive: true, Include: []string{"f.png", "sub/deep/g.png"}}, []string{"sub/deep/f.png", "sub/deep/g.png"}},
+		// exclude on the name and the relative path
+		{&Args{Recursive: true, Exclude: []string{"*.png"}}, []string{"sub/e.jpg"}},
+		{&Args{Recursive: true, Exclude: []string{"deep"}}, []string{".dir/c.png", ".hidden.png", "a.png", "sub/d.png", "sub/e.jpg"}},
+		{&Args{Recursive: true, Exclude: []string{"sub/deep"}}, []string{".dir/c.png", ".hidden.png", "a.png", "sub/d.png", "sub/e.jpg"}},
+		{&Args{Recursive: true, Exclude: []string{"deep/f.png"}}, all},
+		{&Args{Recursive: true, Include: []string{"*.png"}, Exclude: []string{".*", "sub/d.png"}}, []string{"a.png", "sub/deep/f.png", "sub/deep/g.png"}},
+		// symlinked directories, where the loop back to the root terminates
+		{&Args{Recursive: true, FollowSymlinks: true}, []string{".dir/c.png", ".hidden.png", "a.png", "link/d.png", "link/deep/f.png", "link/deep/g.png", "link/e.jpg"}},
+		{&Args{Recursive: true, FollowSymlinks: true, NoHidden: true, Exclude: []string{"link"}}, []string{"a.png", "sub/d.png", "sub/deep/f.png", "sub/deep/g.png", "sub/e.jpg"}},
+	}
+	for i, test := range tests {
+		test.args.logger = t.Logf
+		targets, err := test.args.walk(root)
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		var paths []string
+		for _, target := range targets {
+			rel, err := filepath.Rel(root, target.path)
+			if err != nil {
+				t.Fatalf("test %d expected no error, got: %v", i, err)
+			}
+			paths = append(paths, filepath.ToSlash(rel))
+		}
+		if !slices.Equal(paths, test.exp) {
+			t.Errorf("test %d expected %q, got: %q", i, test.exp, paths)
+		}
+	}
+}
+
+func TestMatchAny(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		name     string
+		rel      string
+		exp      bool
+	}{
+		{nil, "a.png", "a.png", false},
+		{[]string{"*.png"}, "a.png", "sub/a.png", true},
+		{[]string{"sub/*"}, "a.png", "sub/a.png", true},
+		{[]string{"sub/*"}, "a.png", "sub/deep/a.png", false},
+		{[]string{"*.jpg", "a.*"}, "a.png", "a.png", true},
+		{[]string{"["}, "a.png", "a.png", false},
+	}
+	for i, test := range tests {
+		if ok := matchAny(test.patterns, test.name, test.rel); ok != test.exp {
+			t.Errorf("test %d expected %t, got: %t", i, test.exp, ok)
+		}
+	}
+}